@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"syscall"
@@ -19,6 +20,8 @@ import (
 	"obsidian-ai-agent/internal/chroma"
 	"obsidian-ai-agent/internal/httpserver"
 	"obsidian-ai-agent/internal/indexer"
+	"obsidian-ai-agent/internal/tokenizer"
+	"obsidian-ai-agent/internal/watcher"
 )
 
 //go:embed chroma-config.yaml
@@ -30,15 +33,35 @@ func main() {
 
 	var (
 		vaultPath  = flag.String("vault", ".", "Path to the Obsidian vault")
-		dirs       = flag.String("dirs", "Zettelkasten,Projects", "Comma-separated list of directories to index")
-		interval   = flag.Duration("interval", 5*time.Minute, "Reindex interval (e.g., 5m, 30s, 1h)")
+		include    = flag.String("include", "**/*.md", "Comma-separated glob patterns (relative to the vault root) of notes to index; prefix with ! to exclude")
+		exclude    = flag.String("exclude", "", "Comma-separated glob patterns of notes or folders to skip (e.g. Archive/**)")
+		dirs       = flag.String("dirs", "", "Comma-separated list of directories to index (shorthand for -include dir/**/*.md)")
+		interval   = flag.Duration("interval", 5*time.Minute, "Full reindex interval (e.g., 5m, 30s, 1h); a safety net when watching")
+		watch      = flag.Bool("watch", true, "Watch the vault directories and index changed notes immediately")
+		debounce   = flag.Duration("debounce", 2*time.Second, "Quiet period before indexing watched changes")
 		host       = flag.String("host", "localhost", "ChromaDB host")
 		port       = flag.Int("port", 8037, "ChromaDB port")
 		collection = flag.String("collection", "notes", "ChromaDB collection name")
 		batchSize  = flag.Int("batch", 50, "Batch size for document uploads")
+		workers    = flag.Int("workers", runtime.NumCPU(), "Number of files read and chunked concurrently")
+		upserts    = flag.Int("upsert-workers", 2, "Number of batches uploaded and embedded concurrently")
+		transclude = flag.Int("transclusion-depth", 0, "Inline ![[embedded]] notes and sections up to this many levels deep (0 disables)")
+		queries    = flag.Bool("record-queries", false, "Store dataview, dataviewjs and tasks query sources as note metadata")
+		tokenFile  = flag.String("tokenizer", "", "Tokenizer of the embedding model (tokenizer.json or vocab.txt) used to size chunks and report truncated chunks (default: chroma's cached all-MiniLM-L6-v2 tokenizer)")
+		maxTokens  = flag.Int("max-tokens", tokenizer.DefaultMaxLength, "Input length of the embedding model in tokens, including its special tokens")
+		chunkToks  = flag.Int("chunk-tokens", 0, "Target chunk size in embedding model tokens, capped at the model's input limit; requires the model's tokenizer (0 sizes chunks in characters)")
+		overlapTok = flag.Int("chunk-overlap-tokens", 24, "Overlap between chunks in embedding model tokens")
+		strategy   = flag.String("chunk-strategy", "paragraphs", "How sections longer than a chunk are split: paragraphs (overlap in tokens or characters), sentences (overlap in sentences) or semantic (cut at topic shifts)")
+		overlapSen = flag.Int("overlap-sentences", 1, "Sentences repeated at the start of the next chunk with -chunk-strategy sentences")
+		enrichment = flag.String("enrichment", "frontmatter", "Context embedded with each chunk: frontmatter (summary, tags and categories as text), none or headings (frontmatter plus section breadcrumbs)")
+		profiles   = flag.String("profiles", "", "YAML file of chunking profiles that set the strategy, size, overlap and enrichment of notes matching their paths or tags")
+		cutPercent = flag.Float64("semantic-percentile", 10, "With -chunk-strategy semantic, cut between sentences whose similarity is in this lowest percentile of a section")
 		httpPort   = flag.Int("http-port", 8087, "HTTP API server port (0 to disable)")
 		enableHTTP = flag.Bool("enable-http", true, "Enable HTTP API server")
 		clearOnly  = flag.Bool("clear", false, "Clear the collection and exit (does not start the http server)")
+		stateKind  = flag.String("state", "json", "Index state store: json (file in the vault), bolt (database outside the vault) or collection (rebuilt from chunk metadata)")
+		statePath  = flag.String("state-path", "", "Path of the json or bolt state store (default: vault root for json, user config directory for bolt)")
+		rebuild    = flag.Bool("rebuild-state", false, "Rebuild the index state from the collection into the -state store and exit")
 	)
 	flag.Parse()
 
@@ -48,8 +71,18 @@ func main() {
 	} else {
 		log.Printf("Starting Obsidian Chroma Sidecar")
 		log.Printf("Vault: %s", *vaultPath)
-		log.Printf("Directories: %s", *dirs)
+		if *dirs != "" {
+			log.Printf("Directories: %s", *dirs)
+		} else {
+			log.Printf("Include: %s", *include)
+		}
+		if *exclude != "" {
+			log.Printf("Exclude: %s", *exclude)
+		}
 		log.Printf("Reindex interval: %s", *interval)
+		if *watch {
+			log.Printf("Watching for changes (debounce: %s)", *debounce)
+		}
 		if *enableHTTP && *httpPort > 0 {
 			log.Printf("HTTP API enabled on port: %d", *httpPort)
 		} else {
@@ -95,32 +128,83 @@ func main() {
 
 	log.Printf("Connected to ChromaDB at %s:%d, collection: %s", *host, *port, *collection)
 
+	stateStore, closeStateStore, err := openStateStore(*stateKind, *statePath, *vaultPath, client)
+	if err != nil {
+		log.Fatalf("Failed to open index state store: %v", err)
+	}
+	defer closeStateStore()
+
 	// Handle clear-only mode
 	if *clearOnly {
-		if err := clearCollection(ctx, client, *collection, *vaultPath); err != nil {
+		if err := clearCollection(ctx, client, *collection, stateStore); err != nil {
 			log.Fatalf("Failed to clear collection: %v", err)
 		}
 		return
 	}
 
+	// Handle rebuild-state mode
+	if *rebuild {
+		if err := rebuildState(ctx, client, *vaultPath, stateStore); err != nil {
+			log.Fatalf("Failed to rebuild index state: %v", err)
+		}
+		return
+	}
+
 	// Create indexer with default config and override specific values
 	indexerConfig := indexer.DefaultConfig()
 	indexerConfig.VaultPath = *vaultPath
 	indexerConfig.BatchSize = *batchSize
-	indexerConfig.Directories = strings.Split(*dirs, ",")
+	indexerConfig.Workers = *workers
+	indexerConfig.UpsertWorkers = *upserts
+	indexerConfig.TransclusionDepth = *transclude
+	indexerConfig.RecordQueries = *queries
+	indexerConfig.Exclude = splitList(*exclude)
+	if *dirs != "" {
+		indexerConfig.Include = nil
+		indexerConfig.Directories = splitList(*dirs)
+	} else {
+		indexerConfig.Include = splitList(*include)
+	}
+	indexerConfig.StateStore = stateStore
+	if indexerConfig.ChunkStrategy, err = indexer.ParseChunkStrategy(*strategy); err != nil {
+		log.Fatalf("Invalid -chunk-strategy: %v", err)
+	}
+	indexerConfig.OverlapSentences = *overlapSen
+	if indexerConfig.Enrichment, err = indexer.ParseEnrichment(*enrichment); err != nil {
+		log.Fatalf("Invalid -enrichment: %v", err)
+	}
+	if *profiles != "" {
+		if indexerConfig.Profiles, err = indexer.LoadChunkProfiles(*profiles); err != nil {
+			log.Fatalf("Invalid -profiles: %v", err)
+		}
+		log.Printf("Loaded %d chunking profiles from %s", len(indexerConfig.Profiles), *profiles)
+	}
+	indexerConfig.Embedder = client
+	indexerConfig.SemanticPercentile = *cutPercent
+	modelTokenizer, err := loadTokenizer(*tokenFile, *maxTokens)
+	switch {
+	case err != nil && *chunkToks > 0:
+		log.Fatalf("Cannot size chunks in tokens (-chunk-tokens %d): %v", *chunkToks, err)
+	case err != nil:
+		log.Printf("Warning: %v; truncated chunks are not reported", err)
+	default:
+		indexerConfig.Tokenizer = modelTokenizer
+		indexerConfig.ChunkTokens = *chunkToks
+		indexerConfig.ChunkOverlapTokens = *overlapTok
+	}
 
 	obsidianIndexer := indexer.NewObsidianIndexer(client, indexerConfig)
 
 	// Perform initial indexing
 	log.Println("Performing initial indexing...")
-	if err := performIndexing(ctx, obsidianIndexer, indexerConfig.Directories); err != nil {
+	if err := performIndexing(ctx, obsidianIndexer); err != nil {
 		log.Printf("Initial indexing failed: %v", err)
 	}
 
 	// Start HTTP server if enabled
 	var httpSrv *httpserver.Server
 	if *enableHTTP && *httpPort > 0 {
-		httpSrv = httpserver.NewServer(client, *httpPort)
+		httpSrv = httpserver.NewServer(client, obsidianIndexer, *vaultPath, *httpPort)
 		go func() {
 			if err := httpSrv.Start(); err != nil && err != http.ErrServerClosed {
 				log.Printf("HTTP server failed: %v", err)
@@ -128,6 +212,19 @@ func main() {
 		}()
 	}
 
+	// Start watching for changes; the periodic full scan below catches anything the watcher misses
+	var changes <-chan []string
+	if *watch {
+		vaultWatcher, err := watcher.New(obsidianIndexer.WatchRoots(), *debounce, obsidianIndexer.IsIgnoredDir)
+		if err != nil {
+			log.Printf("Failed to start filesystem watcher, relying on periodic reindexing: %v", err)
+		} else {
+			defer vaultWatcher.Close()
+			go vaultWatcher.Run(ctx)
+			changes = vaultWatcher.Changes()
+		}
+	}
+
 	// Start periodic indexing
 	ticker := time.NewTicker(*interval)
 	defer ticker.Stop()
@@ -161,12 +258,22 @@ func main() {
 				log.Println("ChromaDB stopped successfully")
 			}
 
+			closeStateStore()
 			log.Println("Sidecar stopped")
 			os.Exit(0)
 
+		case paths, ok := <-changes:
+			if !ok {
+				changes = nil // Watcher stopped; keep relying on the ticker
+				continue
+			}
+			if err := performFileIndexing(ctx, obsidianIndexer, paths); err != nil {
+				log.Printf("Indexing changed files failed: %v", err)
+			}
+
 		case <-ticker.C:
 			log.Printf("Starting scheduled reindex at %s", time.Now().Format("15:04:05"))
-			if err := performIndexing(ctx, obsidianIndexer, indexerConfig.Directories); err != nil {
+			if err := performIndexing(ctx, obsidianIndexer); err != nil {
 				log.Printf("Scheduled indexing failed: %v", err)
 			}
 		}
@@ -252,10 +359,10 @@ func createTempConfigFile() (string, error) {
 	return filepath.Abs(tempFile.Name())
 }
 
-func performIndexing(ctx context.Context, indexer *indexer.ObsidianIndexer, directories []string) error {
+func performIndexing(ctx context.Context, indexer *indexer.ObsidianIndexer) error {
 	start := time.Now()
 
-	result, err := indexer.ReindexVault(ctx, directories)
+	result, err := indexer.ReindexVault(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("reindexing failed: %w", err)
 	}
@@ -263,8 +370,8 @@ func performIndexing(ctx context.Context, indexer *indexer.ObsidianIndexer, dire
 	duration := time.Since(start)
 
 	log.Printf("=== Indexing Complete (took %s) ===", duration.Round(time.Millisecond))
-	log.Printf("Processed: %d, New: %d, Updated: %d, Skipped: %d, Errors: %d",
-		result.ProcessedFiles, result.IndexedFiles, result.UpdatedFiles, result.SkippedFiles, len(result.Errors))
+	log.Printf("Processed: %d, New: %d, Updated: %d, Renamed: %d, Skipped: %d, Ignored: %d, Failed: %d, Deleted: %d, Errors: %d",
+		result.ProcessedFiles, result.IndexedFiles, result.UpdatedFiles, result.RenamedFiles, result.SkippedFiles, result.IgnoredFiles, result.FailedFiles, result.DeletedFiles, len(result.Errors))
 
 	if len(result.Errors) > 0 {
 		log.Printf("Errors encountered:")
@@ -281,7 +388,110 @@ func performIndexing(ctx context.Context, indexer *indexer.ObsidianIndexer, dire
 	return nil
 }
 
-func clearCollection(ctx context.Context, client *chroma.Client, collectionName, vaultPath string) error {
+func performFileIndexing(ctx context.Context, indexer *indexer.ObsidianIndexer, paths []string) error {
+	result, err := indexer.IndexFiles(ctx, paths)
+	if err != nil {
+		return fmt.Errorf("indexing changed files failed: %w", err)
+	}
+
+	if result.IndexedFiles+result.UpdatedFiles+result.RenamedFiles+result.DeletedFiles > 0 {
+		log.Printf("Indexed changes: New: %d, Updated: %d, Renamed: %d, Deleted: %d, Errors: %d",
+			result.IndexedFiles, result.UpdatedFiles, result.RenamedFiles, result.DeletedFiles, len(result.Errors))
+	}
+
+	for _, err := range result.Errors {
+		log.Printf("  %v", err)
+	}
+
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadTokenizer loads the tokenizer of the embedding model, by default chroma's cached one
+func loadTokenizer(path string, maxLength int) (tokenizer.Tokenizer, error) {
+	if path == "" {
+		defaultPath, err := tokenizer.DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = defaultPath
+	}
+
+	modelTokenizer, err := tokenizer.Load(path, maxLength)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tokenizer: %w", err)
+	}
+	log.Printf("Tokenizer: %s (%d tokens per chunk embedded)", path, modelTokenizer.MaxTokens())
+	return modelTokenizer, nil
+}
+
+// openStateStore opens the index state store selected with the -state flag
+func openStateStore(kind, path, vaultPath string, client *chroma.Client) (indexer.StateStore, func(), error) {
+	switch kind {
+	case "json":
+		if path == "" {
+			path = filepath.Join(vaultPath, indexer.IndexFileName)
+		}
+		log.Printf("Index state: %s", path)
+		return indexer.NewJSONStateStore(path, vaultPath), func() {}, nil
+
+	case "bolt":
+		if path == "" {
+			defaultPath, err := indexer.DefaultBoltStatePath(vaultPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to determine state database path: %w", err)
+			}
+			path = defaultPath
+		}
+		store, err := indexer.OpenBoltStateStore(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Index state: %s", path)
+
+		var closeOnce sync.Once
+		return store, func() {
+			closeOnce.Do(func() {
+				if err := store.Close(); err != nil {
+					log.Printf("Warning: Failed to close state database: %v", err)
+				}
+			})
+		}, nil
+
+	case "collection":
+		log.Printf("Index state: rebuilt from collection metadata")
+		return indexer.NewCollectionStateStore(client, vaultPath), func() {}, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown state store %q (expected json, bolt or collection)", kind)
+	}
+}
+
+func rebuildState(ctx context.Context, client *chroma.Client, vaultPath string, store indexer.StateStore) error {
+	files, err := indexer.NewCollectionStateStore(client, vaultPath).Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Save(ctx, files); err != nil {
+		return fmt.Errorf("failed to save index state: %w", err)
+	}
+
+	log.Printf("Rebuilt index state for %d files from the collection", len(files))
+	return nil
+}
+
+func clearCollection(ctx context.Context, client *chroma.Client, collectionName string, store indexer.StateStore) error {
 	// Get document count before clearing
 	count, err := client.GetDocumentCount(ctx)
 	if err != nil {
@@ -303,16 +513,11 @@ func clearCollection(ctx context.Context, client *chroma.Client, collectionName,
 
 	log.Printf("Successfully cleared %d documents from collection '%s'", count, collectionName)
 
-	// Remove the index tracking file
-	indexFile := filepath.Join(vaultPath, ".obsidian_index.json")
-	if _, err := os.Stat(indexFile); err == nil {
-		if err := os.Remove(indexFile); err != nil {
-			log.Printf("Warning: Failed to remove index tracking file %s: %v", indexFile, err)
-		} else {
-			log.Printf("Removed index tracking file: %s", indexFile)
-		}
+	// Reset the index state so every file is indexed again
+	if err := store.Save(ctx, map[string]indexer.FileIndex{}); err != nil {
+		log.Printf("Warning: Failed to reset index state: %v", err)
 	} else {
-		log.Printf("Index tracking file does not exist: %s", indexFile)
+		log.Printf("Reset index state")
 	}
 
 	return nil
@@ -4,19 +4,22 @@ go 1.24.5
 
 require (
 	github.com/amikos-tech/chroma-go v0.2.3
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/magefile/mage v1.15.0
+	github.com/mozillazg/go-unidecode v0.2.0
 	github.com/stretchr/testify v1.10.0
+	go.etcd.io/bbolt v1.4.3
 	golang.org/x/text v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/go-viper/mapstructure/v2 v2.2.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/mozillazg/go-unidecode v0.2.0 // indirect
 	github.com/oklog/ulid v1.3.1 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/yalue/onnxruntime_go v1.19.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sys v0.32.0 // indirect
 )
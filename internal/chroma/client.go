@@ -5,12 +5,15 @@ import (
 	"fmt"
 
 	v2 "github.com/amikos-tech/chroma-go/pkg/api/v2"
+	"github.com/amikos-tech/chroma-go/pkg/embeddings"
+	defaultef "github.com/amikos-tech/chroma-go/pkg/embeddings/default_ef"
 )
 
 // Client wraps the ChromaDB client with convenience methods
 type Client struct {
-	client     v2.Client
-	collection v2.Collection
+	client            v2.Client
+	collection        v2.Collection
+	embeddingFunction embeddings.EmbeddingFunction
 }
 
 // Config holds ChromaDB connection configuration
@@ -18,6 +21,9 @@ type Config struct {
 	Host           string
 	Port           int
 	CollectionName string
+	// EmbeddingFunction embeds the collection's documents and queries (default: chroma's default
+	// embedding function, all-MiniLM-L6-v2)
+	EmbeddingFunction embeddings.EmbeddingFunction
 }
 
 // DefaultConfig returns default ChromaDB configuration
@@ -38,15 +44,24 @@ func NewClient(ctx context.Context, config *Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to create chroma client: %w", err)
 	}
 
+	embeddingFunction := config.EmbeddingFunction
+	if embeddingFunction == nil {
+		embeddingFunction, _, err = defaultef.NewDefaultEmbeddingFunction()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create default embedding function: %w", err)
+		}
+	}
+
 	// Get or create collection using v2 API
-	collection, err := client.GetOrCreateCollection(ctx, config.CollectionName)
+	collection, err := client.GetOrCreateCollection(ctx, config.CollectionName, v2.WithEmbeddingFunctionCreate(embeddingFunction))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get/create collection '%s': %w", config.CollectionName, err)
 	}
 
 	return &Client{
-		client:     client,
-		collection: collection,
+		client:            client,
+		collection:        collection,
+		embeddingFunction: embeddingFunction,
 	}, nil
 }
 
@@ -57,6 +72,25 @@ type Document struct {
 	Metadata map[string]interface{}
 }
 
+// DocumentMove describes an existing document that is stored again under a new ID
+type DocumentMove struct {
+	OldID    string
+	Document Document
+}
+
+// ChunkInfo holds the bookkeeping metadata stored on an indexed chunk
+type ChunkInfo struct {
+	ID           string
+	Path         string
+	ChunkIndex   int64
+	ContentHash  string
+	LastModified int64
+	// NormalizerVersion is the version of the markdown normaliser the chunk's text was rendered with
+	NormalizerVersion int64
+	// ChunkSettings is the fingerprint of the chunking settings the chunk's note was indexed with
+	ChunkSettings string
+}
+
 // AddDocuments adds multiple documents to the collection
 func (c *Client) AddDocuments(ctx context.Context, documents []Document) error {
 	if len(documents) == 0 {
@@ -123,6 +157,142 @@ func (c *Client) UpsertDocuments(ctx context.Context, documents []Document) erro
 	return nil
 }
 
+// DeleteDocuments removes the documents with the given IDs from the collection
+func (c *Client) DeleteDocuments(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	err := c.collection.Delete(ctx, v2.WithIDsDelete(convertToDocumentIDs(ids)...))
+	if err != nil {
+		return fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteDocumentsByPath removes all documents whose "path" metadata matches the given path,
+// except the documents with one of keepIDs
+func (c *Client) DeleteDocumentsByPath(ctx context.Context, path string, keepIDs []string) error {
+	if len(keepIDs) == 0 {
+		err := c.collection.Delete(ctx, v2.WithWhereDelete(v2.EqString("path", path)))
+		if err != nil {
+			return fmt.Errorf("failed to delete documents for path %s: %w", path, err)
+		}
+		return nil
+	}
+
+	result, err := c.collection.Get(ctx, v2.WithWhereGet(v2.EqString("path", path)))
+	if err != nil {
+		return fmt.Errorf("failed to get documents for path %s: %w", path, err)
+	}
+
+	keep := make(map[string]bool, len(keepIDs))
+	for _, id := range keepIDs {
+		keep[id] = true
+	}
+	var ids []string
+	for _, id := range result.GetIDs() {
+		if !keep[string(id)] {
+			ids = append(ids, string(id))
+		}
+	}
+
+	return c.DeleteDocuments(ctx, ids)
+}
+
+// MoveDocuments stores existing documents under new (or their current) IDs with new content and
+// metadata, reusing their stored embeddings so that nothing is re-embedded
+func (c *Client) MoveDocuments(ctx context.Context, moves []DocumentMove) error {
+	if len(moves) == 0 {
+		return nil
+	}
+
+	oldIDs := make([]string, len(moves))
+	for i, move := range moves {
+		oldIDs[i] = move.OldID
+	}
+
+	// Fetch the stored embeddings of the existing documents
+	result, err := c.collection.Get(ctx,
+		v2.WithIDsGet(convertToDocumentIDs(oldIDs)...),
+		v2.WithIncludeGet(v2.IncludeEmbeddings),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get documents to move: %w", err)
+	}
+
+	storedEmbeddings := make(map[string]embeddings.Embedding)
+	resultEmbeddings := result.GetEmbeddings()
+	for i, id := range result.GetIDs() {
+		if i < len(resultEmbeddings) {
+			storedEmbeddings[string(id)] = resultEmbeddings[i]
+		}
+	}
+
+	ids := make([]string, len(moves))
+	contents := make([]string, len(moves))
+	metadatas := make([]map[string]interface{}, len(moves))
+	vectors := make([]embeddings.Embedding, len(moves))
+	newIDs := make(map[string]bool, len(moves))
+
+	for i, move := range moves {
+		embedding, ok := storedEmbeddings[move.OldID]
+		if !ok || embedding == nil {
+			return fmt.Errorf("no stored embedding found for document %s", move.OldID)
+		}
+		ids[i] = move.Document.ID
+		contents[i] = move.Document.Content
+		metadatas[i] = move.Document.Metadata
+		vectors[i] = embedding
+		newIDs[move.Document.ID] = true
+	}
+
+	docMetadatas, err := convertToDocumentMetadatas(metadatas)
+	if err != nil {
+		return fmt.Errorf("failed to convert metadatas: %w", err)
+	}
+
+	err = c.collection.Upsert(ctx,
+		v2.WithTexts(contents...),
+		v2.WithIDs(convertToDocumentIDs(ids)...),
+		v2.WithMetadatas(docMetadatas...),
+		v2.WithEmbeddings(vectors...),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert moved documents: %w", err)
+	}
+
+	// Remove the documents under their old IDs (unless an ID was reused)
+	var staleIDs []string
+	for _, id := range oldIDs {
+		if !newIDs[id] {
+			staleIDs = append(staleIDs, id)
+		}
+	}
+
+	return c.DeleteDocuments(ctx, staleIDs)
+}
+
+// EmbedTexts embeds texts with the collection's embedding function without storing them
+func (c *Client) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	result, err := c.embeddingFunction.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed texts: %w", err)
+	}
+
+	vectors := make([][]float32, len(result))
+	for i, embedding := range result {
+		vectors[i] = embedding.ContentAsFloat32()
+	}
+
+	return vectors, nil
+}
+
 // DocumentExists checks if a document with the given ID exists in the collection
 func (c *Client) DocumentExists(ctx context.Context, id string) (bool, error) {
 	result, err := c.collection.Get(ctx, v2.WithIDsGet(v2.DocumentID(id)))
@@ -158,12 +328,59 @@ func (c *Client) GetDocumentMetadata(ctx context.Context, id string) (map[string
 	return metadata, nil
 }
 
+// ListChunks returns the bookkeeping metadata of every document in the collection
+func (c *Client) ListChunks(ctx context.Context) ([]ChunkInfo, error) {
+	const pageSize = 1000
+
+	var chunks []ChunkInfo
+	for offset := 0; ; offset += pageSize {
+		result, err := c.collection.Get(ctx,
+			v2.WithIncludeGet(v2.IncludeMetadatas),
+			v2.WithLimitGet(pageSize),
+			v2.WithOffsetGet(offset),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list documents: %w", err)
+		}
+
+		ids := result.GetIDs()
+		metadatas := result.GetMetadatas()
+		for i, id := range ids {
+			chunk := ChunkInfo{ID: string(id)}
+			if i < len(metadatas) && metadatas[i] != nil {
+				chunk.Path, _ = metadatas[i].GetString("path")
+				chunk.ChunkIndex, _ = metadatas[i].GetInt("chunk_index")
+				chunk.ContentHash, _ = metadatas[i].GetString("content_hash")
+				chunk.LastModified, _ = metadatas[i].GetInt("last_modified")
+				chunk.NormalizerVersion, _ = metadatas[i].GetInt("normalizer_version")
+				chunk.ChunkSettings, _ = metadatas[i].GetString("chunk_settings")
+			}
+			chunks = append(chunks, chunk)
+		}
+
+		if len(ids) < pageSize {
+			return chunks, nil
+		}
+	}
+}
+
 // Query performs a semantic search query
 func (c *Client) Query(ctx context.Context, queryText string, nResults int32) (v2.QueryResult, error) {
-	result, err := c.collection.Query(ctx,
+	return c.QueryWhere(ctx, queryText, nResults, nil)
+}
+
+// QueryWhere queries the collection for documents similar to queryText whose metadata matches
+// where; a nil filter matches every document
+func (c *Client) QueryWhere(ctx context.Context, queryText string, nResults int32, where v2.WhereFilter) (v2.QueryResult, error) {
+	options := []v2.CollectionQueryOption{
 		v2.WithQueryTexts(queryText),
 		v2.WithNResults(int(nResults)),
-	)
+	}
+	if where != nil {
+		options = append(options, v2.WithWhereQuery(where))
+	}
+
+	result, err := c.collection.Query(ctx, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query collection: %w", err)
 	}
@@ -153,7 +153,7 @@ short mode
 	assert.Contains(t, result, "Test content", "Test content should be preserved in extract output")
 }
 
-func TestCleanMarkdown_RemovesCodeBlocks(t *testing.T) {
+func TestCleanMarkdown_KeepsCodeBlocks(t *testing.T) {
 	server := &Server{}
 
 	content := `# Some Content
@@ -174,13 +174,29 @@ Some other code
 
 Final text.`
 
-	result := server.cleanMarkdown(content)
+	result := server.extractQueryText(content)
 
-	expected := "Some Content\n\nHere is some regular text.\n\n\n\nMore text after code block.\n\n\n\nFinal text."
+	// Queries are normalised like indexed notes, which embed their code blocks
+	expected := "# Some Content Here is some regular text. func main() { fmt.Println(\"Hello\") } More text after code block. Some other code Final text."
 	assert.Equal(t, expected, result)
+}
+
+func TestCleanMarkdown_RemovesCommentsAndHighlightMarkers(t *testing.T) {
+	server := &Server{}
+
+	content := "Meeting notes %%private aside%%\n%%\nscratch\n%%\nThe ==decision== was made."
+
+	result := server.extractQueryText(content)
+
+	assert.Equal(t, "Meeting notes The decision was made.", result)
+}
+
+func TestExtractQueryText_RendersInlineFieldsAndCallouts(t *testing.T) {
+	server := &Server{}
+
+	content := "> [!tip] Remember\n> status:: active\n\nDue [due:: 2025-01-01]"
+
+	result := server.extractQueryText(content)
 
-	// Code blocks should be completely removed
-	assert.NotContains(t, result, "func main")
-	assert.NotContains(t, result, "Hello")
-	assert.NotContains(t, result, "Some other code")
+	assert.Equal(t, "Tip: Remember status: active Due due: 2025-01-01", result)
 }
@@ -0,0 +1,50 @@
+package httpserver
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeepLink(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "My Vault")
+	server := &Server{vaultPath: vaultPath}
+
+	tests := []struct {
+		name             string
+		metadata         map[string]interface{}
+		expectedURI      string
+		expectedFragment string
+	}{
+		{
+			"heading",
+			map[string]interface{}{"path": filepath.Join(vaultPath, "Projects", "Atlas & Co.md"), "heading": "Next steps"},
+			"obsidian://open?vault=My%20Vault&file=Projects%2FAtlas%20%26%20Co.md",
+			"#Next steps",
+		},
+		{
+			"block takes precedence over heading",
+			map[string]interface{}{"path": filepath.Join(vaultPath, "Note.md"), "heading": "Intro", "block_ids": "quote-1, summary"},
+			"obsidian://open?vault=My%20Vault&file=Note.md",
+			"#^quote-1",
+		},
+		{
+			"outside the vault",
+			map[string]interface{}{"path": filepath.Join(filepath.Dir(vaultPath), "Other.md")},
+			"",
+			"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, fragment := server.deepLink(tt.metadata)
+			assert.Equal(t, tt.expectedURI, uri)
+			assert.Equal(t, tt.expectedFragment, fragment)
+		})
+	}
+
+	uri, _ := (&Server{}).deepLink(map[string]interface{}{"path": "Note.md"})
+	assert.Empty(t, uri, "no URI without a vault path")
+}
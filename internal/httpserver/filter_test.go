@@ -0,0 +1,42 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWhereFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      SimilarityRequest
+		expected string
+	}{
+		{"no filters", SimilarityRequest{}, ""},
+		{"chunk type", SimilarityRequest{ChunkType: "task"}, `{"chunk_type":{"$eq":"task"}}`},
+		{
+			"open tasks due this month",
+			SimilarityRequest{TaskStatus: "open", DueAfter: "2025-10-01", DueBefore: "2025-10-31"},
+			`{"$and":[{"chunk_type":{"$eq":"task"}},{"task_status":{"$eq":"open"}},{"task_due":{"$gte":1759276800}},{"task_due":{"$lte":1761868800}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, err := whereFilter(tt.req)
+			require.NoError(t, err)
+			if tt.expected == "" {
+				assert.Nil(t, where)
+				return
+			}
+			data, err := json.Marshal(where)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(data))
+		})
+	}
+
+	_, err := whereFilter(SimilarityRequest{DueBefore: "next week"})
+	assert.Error(t, err)
+}
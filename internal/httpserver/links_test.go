@@ -0,0 +1,64 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"obsidian-ai-agent/internal/indexer"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLinkSource map[string]*indexer.NoteLinks
+
+func (f fakeLinkSource) NoteLinks(path string) (*indexer.NoteLinks, error) {
+	if links, ok := f[path]; ok {
+		return links, nil
+	}
+	return nil, fmt.Errorf("note %s is not indexed", path)
+}
+
+func TestHandleNoteLinks(t *testing.T) {
+	source := fakeLinkSource{
+		"Home.md": {
+			Path:       "Home.md",
+			Links:      []indexer.Link{{Target: "Alpha", Resolved: "Alpha.md"}},
+			Unresolved: []string{},
+			Backlinks:  []indexer.Backlink{{Source: "Alpha.md", Link: indexer.Link{Target: "Home", Resolved: "Home.md"}}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		server   *Server
+		method   string
+		query    string
+		expected int
+	}{
+		{"found", &Server{links: source}, "GET", "?path=Home.md", http.StatusOK},
+		{"missing path", &Server{links: source}, "GET", "", http.StatusBadRequest},
+		{"unknown note", &Server{links: source}, "GET", "?path=Other.md", http.StatusNotFound},
+		{"no link source", &Server{}, "GET", "?path=Home.md", http.StatusServiceUnavailable},
+		{"wrong method", &Server{links: source}, "POST", "?path=Home.md", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/notes/links"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			tt.server.handleNoteLinks(rec, req)
+			assert.Equal(t, tt.expected, rec.Code)
+
+			if tt.expected == http.StatusOK {
+				var response indexer.NoteLinks
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&response))
+				assert.Equal(t, *source["Home.md"], response)
+			}
+		})
+	}
+}
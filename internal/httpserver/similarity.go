@@ -6,23 +6,40 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
 
 	"obsidian-ai-agent/internal/chroma"
+	"obsidian-ai-agent/internal/indexer"
+	"obsidian-ai-agent/internal/markdown"
 
 	v2 "github.com/amikos-tech/chroma-go/pkg/api/v2"
 )
 
 type Server struct {
 	chromaClient *chroma.Client
+	links        LinkSource
+	vaultPath    string
 	httpServer   *http.Server
 }
 
+// LinkSource provides the link graph built by the indexer
+type LinkSource interface {
+	NoteLinks(path string) (*indexer.NoteLinks, error)
+}
+
 type SimilarityRequest struct {
 	Content string `json:"content"`
 	Limit   int    `json:"limit,omitempty"`
+	// ChunkType restricts results to one kind of chunk, e.g. "task"
+	ChunkType string `json:"chunk_type,omitempty"`
+	// TaskStatus, DueAfter and DueBefore (inclusive, YYYY-MM-DD) restrict results to matching tasks
+	TaskStatus string `json:"task_status,omitempty"`
+	DueAfter   string `json:"due_after,omitempty"`
+	DueBefore  string `json:"due_before,omitempty"`
 }
 
 type SimilarityResult struct {
@@ -30,6 +47,10 @@ type SimilarityResult struct {
 	Content  string                 `json:"content"`
 	Metadata map[string]interface{} `json:"metadata"`
 	Distance float64                `json:"distance"`
+	// URI opens the note in Obsidian, e.g. "obsidian://open?vault=Notes&file=Projects%2FAtlas.md"
+	URI string `json:"uri,omitempty"`
+	// Fragment is the block ("#^block-id") or heading ("#Heading") the result is in
+	Fragment string `json:"fragment,omitempty"`
 }
 
 type SimilarityResponse struct {
@@ -38,12 +59,16 @@ type SimilarityResponse struct {
 	Limit   int                `json:"limit"`
 }
 
-// NewServer creates a new HTTP server for similarity queries
-func NewServer(chromaClient *chroma.Client, port int) *Server {
+// NewServer creates a new HTTP server for similarity and link queries. links may be nil,
+// in which case the links endpoint reports that it is unavailable. vaultPath is used to build
+// obsidian:// links to the results.
+func NewServer(chromaClient *chroma.Client, links LinkSource, vaultPath string, port int) *Server {
 	mux := http.NewServeMux()
 
 	server := &Server{
 		chromaClient: chromaClient,
+		links:        links,
+		vaultPath:    vaultPath,
 		httpServer: &http.Server{
 			Addr:    fmt.Sprintf(":%d", port),
 			Handler: mux,
@@ -53,6 +78,7 @@ func NewServer(chromaClient *chroma.Client, port int) *Server {
 	// Setup routes
 	mux.HandleFunc("/similarity", server.enableCORS(server.handleSimilarity))
 	mux.HandleFunc("/health", server.enableCORS(server.handleHealth))
+	mux.HandleFunc("/notes/links", server.enableCORS(server.handleNoteLinks))
 
 	return server
 }
@@ -120,6 +146,35 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+func (s *Server) handleNoteLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.links == nil {
+		http.Error(w, "Link graph not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	path := strings.TrimSpace(r.URL.Query().Get("path"))
+	if path == "" {
+		http.Error(w, "path parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	links, err := s.links.NoteLinks(path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(links); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
 func (s *Server) handleSimilarity(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -146,6 +201,12 @@ func (s *Server) handleSimilarity(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	where, err := whereFilter(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Extract meaningful query text from the markdown content
 	queryText := s.extractQueryText(req.Content)
 	if queryText == "" {
@@ -154,7 +215,7 @@ func (s *Server) handleSimilarity(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Query ChromaDB for similar documents
-	results, err := s.chromaClient.Query(ctx, queryText, int32(limit))
+	results, err := s.chromaClient.QueryWhere(ctx, queryText, int32(limit), where)
 	if err != nil {
 		log.Printf("ChromaDB query failed: %v", err)
 		http.Error(w, "Query failed", http.StatusInternalServerError)
@@ -172,19 +233,50 @@ func (s *Server) handleSimilarity(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// extractQueryText extracts meaningful text from markdown content for querying
-func (s *Server) extractQueryText(content string) string {
+// whereFilter builds the metadata filter of a similarity request; it returns nil if the request
+// has no filters. Task filters imply chunk_type "task".
+func whereFilter(req SimilarityRequest) (v2.WhereClause, error) {
+	var clauses []v2.WhereClause
 
-	// Remove YAML frontmatter
-	frontmatterRegex := regexp.MustCompile(`(?s)^---.*?---\s*`)
-	content = frontmatterRegex.ReplaceAllString(content, "")
+	chunkType := req.ChunkType
+	if chunkType == "" && (req.TaskStatus != "" || req.DueAfter != "" || req.DueBefore != "") {
+		chunkType = indexer.TaskChunkType
+	}
+	if chunkType != "" {
+		clauses = append(clauses, v2.EqString("chunk_type", chunkType))
+	}
+	if req.TaskStatus != "" {
+		clauses = append(clauses, v2.EqString("task_status", req.TaskStatus))
+	}
+	if req.DueAfter != "" {
+		date, err := time.Parse("2006-01-02", req.DueAfter)
+		if err != nil {
+			return nil, fmt.Errorf("due_after must be a date (YYYY-MM-DD)")
+		}
+		clauses = append(clauses, v2.GteInt("task_due", int(date.Unix())))
+	}
+	if req.DueBefore != "" {
+		date, err := time.Parse("2006-01-02", req.DueBefore)
+		if err != nil {
+			return nil, fmt.Errorf("due_before must be a date (YYYY-MM-DD)")
+		}
+		clauses = append(clauses, v2.LteInt("task_due", int(date.Unix())))
+	}
 
-	// Remove markdown formatting
-	content = s.cleanMarkdown(content)
+	switch len(clauses) {
+	case 0:
+		return nil, nil
+	case 1:
+		return clauses[0], nil
+	default:
+		return v2.And(clauses...), nil
+	}
+}
 
-	// Remove extra whitespace and normalize
-	content = regexp.MustCompile(`\s+`).ReplaceAllString(content, " ")
-	content = strings.TrimSpace(content)
+// extractQueryText extracts meaningful text from markdown content for querying. It is normalised
+// the same way as indexed notes, so queries and notes are embedded alike.
+func (s *Server) extractQueryText(content string) string {
+	content = markdown.Normalize(content, markdown.EmbeddingOptions)
 
 	// If content is too long, take first meaningful chunk
 	if len(content) > 2000 {
@@ -206,35 +298,6 @@ func (s *Server) extractQueryText(content string) string {
 	return content
 }
 
-// cleanMarkdown removes common markdown formatting to get clean text
-func (s *Server) cleanMarkdown(content string) string {
-	// Remove headers
-	content = regexp.MustCompile(`(?m)^#{1,6}\s+`).ReplaceAllString(content, "")
-
-	// Remove code blocks
-	content = regexp.MustCompile("(?s)```.*?```").ReplaceAllString(content, "")
-	content = regexp.MustCompile("`[^`]+`").ReplaceAllString(content, "")
-
-	// Remove links but keep link text
-	content = regexp.MustCompile(`\[([^\]]+)\]\([^)]+\)`).ReplaceAllString(content, "$1")
-	content = regexp.MustCompile(`\[\[([^\]]+)\]\]`).ReplaceAllString(content, "$1")
-
-	// Remove bold/italic formatting
-	content = regexp.MustCompile(`\*\*([^*]+)\*\*`).ReplaceAllString(content, "$1")
-	content = regexp.MustCompile(`\*([^*]+)\*`).ReplaceAllString(content, "$1")
-	content = regexp.MustCompile(`__([^_]+)__`).ReplaceAllString(content, "$1")
-	content = regexp.MustCompile(`_([^_]+)_`).ReplaceAllString(content, "$1")
-
-	// Remove bullet points and list markers
-	content = regexp.MustCompile(`(?m)^[\s]*[-*+]\s+`).ReplaceAllString(content, "")
-	content = regexp.MustCompile(`(?m)^[\s]*\d+\.\s+`).ReplaceAllString(content, "")
-
-	// Remove block quotes
-	content = regexp.MustCompile(`(?m)^>\s*`).ReplaceAllString(content, "")
-
-	return content
-}
-
 // formatResults converts ChromaDB results to our response format
 func (s *Server) formatResults(result v2.QueryResult, queryText string, limit int) SimilarityResponse {
 	response := SimilarityResponse{
@@ -286,8 +349,37 @@ func (s *Server) formatResults(result v2.QueryResult, queryText string, limit in
 			if chunkType, ok := docMeta.GetString("chunk_type"); ok {
 				result.Metadata["chunk_type"] = chunkType
 			}
+
+			// Task documents
+			for _, key := range []string{"task_status", "task_text", "task_priority", "heading"} {
+				if value, ok := docMeta.GetString(key); ok {
+					result.Metadata[key] = value
+				}
+			}
+			for _, key := range []string{"task_due", "task_scheduled", "task_start", "task_done"} {
+				if value, ok := docMeta.GetInt(key); ok {
+					result.Metadata[key] = time.Unix(value, 0).UTC().Format("2006-01-02")
+				}
+			}
+			if line, ok := docMeta.GetInt("task_line"); ok {
+				result.Metadata["task_line"] = line
+			}
+
+			// Location in the note
+			for _, key := range []string{"heading_path", "block_ids"} {
+				if value, ok := docMeta.GetString(key); ok {
+					result.Metadata[key] = value
+				}
+			}
+			for _, key := range []string{"start_line", "end_line"} {
+				if value, ok := docMeta.GetInt(key); ok {
+					result.Metadata[key] = value
+				}
+			}
 		}
 
+		result.URI, result.Fragment = s.deepLink(result.Metadata)
+
 		// Extract distance
 		if len(distanceGroups) > 0 && i < len(distanceGroups[0]) {
 			result.Distance = float64(distanceGroups[0][i])
@@ -298,3 +390,41 @@ func (s *Server) formatResults(result v2.QueryResult, queryText string, limit in
 
 	return response
 }
+
+// deepLink returns the obsidian:// URI that opens the note of a result and the fragment of the
+// block or heading the result is in. The URI is empty when the vault path is unknown.
+func (s *Server) deepLink(metadata map[string]interface{}) (string, string) {
+	var fragment string
+	if blockIDs, ok := metadata["block_ids"].(string); ok && blockIDs != "" {
+		fragment = "#^" + strings.TrimSpace(strings.Split(blockIDs, ",")[0])
+	} else if heading, ok := metadata["heading"].(string); ok && heading != "" {
+		fragment = "#" + heading
+	}
+
+	notePath, ok := metadata["path"].(string)
+	if !ok || notePath == "" || s.vaultPath == "" {
+		return "", fragment
+	}
+
+	vaultPath, err := filepath.Abs(s.vaultPath)
+	if err != nil {
+		return "", fragment
+	}
+	absolutePath, err := filepath.Abs(notePath)
+	if err != nil {
+		return "", fragment
+	}
+	relativePath, err := filepath.Rel(vaultPath, absolutePath)
+	if err != nil || strings.HasPrefix(relativePath, "..") {
+		return "", fragment
+	}
+
+	uri := "obsidian://open?vault=" + uriEscape(filepath.Base(vaultPath)) +
+		"&file=" + uriEscape(filepath.ToSlash(relativePath))
+	return uri, fragment
+}
+
+// uriEscape escapes a query value with %20 for spaces, which Obsidian expects instead of "+"
+func uriEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
@@ -0,0 +1,201 @@
+package indexer
+
+import (
+	"regexp"
+	"strings"
+
+	"obsidian-ai-agent/internal/chroma"
+	"obsidian-ai-agent/internal/markdown"
+)
+
+// blockIDRegex matches a "^block-id" marker at the end of a line
+var blockIDRegex = regexp.MustCompile(`(?:^|\s)\^([A-Za-z0-9-]+)\s*$`)
+
+// anchorWindow is the number of words matched when locating a chunk in its note
+const anchorWindow = 5
+
+// anchorAttempts limits how many words at either end of a chunk are skipped looking for a match,
+// e.g. the frontmatter summary that precedes the first chunk
+const anchorAttempts = 40
+
+// noteHeading is a heading of a note with its 1-based line number
+type noteHeading struct {
+	level int
+	text  string
+	line  int
+}
+
+// sourceMap maps chunk text back to the lines, headings and block IDs of the note it comes from
+type sourceMap struct {
+	words    []markdown.Word // Words of the note as they are embedded
+	headings []noteHeading
+	blockIDs map[int]string // Block ID by 1-based line number
+}
+
+// chunkAnchor is where a chunk starts and ends in its note
+type chunkAnchor struct {
+	StartLine   int
+	EndLine     int
+	HeadingPath []string // Headings above the start of the chunk, outermost first
+	BlockIDs    []string // Block IDs within the chunk's lines
+}
+
+// newSourceMap builds the source map of a note. content must have the same lines as the note file;
+// it is normalised the same way as the embedded text so chunk words can be found in it.
+func (idx *ObsidianIndexer) newSourceMap(content string) *sourceMap {
+	source := &sourceMap{blockIDs: make(map[int]string)}
+
+	lines := strings.Split(content, "\n")
+	start := bodyStartLine(content)
+
+	inFence := ""
+	for i := start; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = trimmed[:3]
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(lines[i]); match != nil {
+			source.headings = append(source.headings, noteHeading{level: len(match[1]), text: match[2], line: i + 1})
+		}
+		if match := blockIDRegex.FindStringSubmatch(lines[i]); match != nil {
+			source.blockIDs[i+1] = match[1]
+		}
+	}
+
+	source.words = markdown.Words(content, markdown.EmbeddingOptions)
+
+	return source
+}
+
+// bodyStartLine returns the 0-based index of the first line after the YAML frontmatter
+func bodyStartLine(content string) int {
+	if _, _, ok := splitYAMLFrontmatter(content); !ok {
+		return 0
+	}
+
+	lines := strings.Split(content, "\n")
+	for i := 1; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// locate finds the words of a chunk in the note, starting the search at word from. It returns the
+// anchor and the word position the chunk starts at, or false if the chunk cannot be found, e.g.
+// because it consists of transcluded text.
+func (s *sourceMap) locate(content string, from int) (chunkAnchor, int, bool) {
+	words := strings.Fields(content)
+
+	start := -1
+	for j := 0; j < len(words) && j < anchorAttempts && start < 0; j++ {
+		start = s.find(words[j:min(j+anchorWindow, len(words))], from)
+	}
+	if start < 0 {
+		return chunkAnchor{}, from, false
+	}
+
+	end := start
+	for j := len(words); j > 0 && len(words)-j < anchorAttempts; j-- {
+		window := words[max(0, j-anchorWindow):j]
+		if position := s.find(window, start); position >= 0 {
+			end = position + len(window) - 1
+			break
+		}
+	}
+
+	return s.anchor(s.words[start].Line, s.words[end].Line), start, true
+}
+
+// find returns the position of the first occurrence of window at or after from, or -1
+func (s *sourceMap) find(window []string, from int) int {
+	for position := from; position+len(window) <= len(s.words); position++ {
+		matched := true
+		for k, word := range window {
+			if s.words[position+k].Text != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return position
+		}
+	}
+	return -1
+}
+
+// anchor returns the heading path at startLine and the block IDs between startLine and endLine
+func (s *sourceMap) anchor(startLine, endLine int) chunkAnchor {
+	anchor := chunkAnchor{StartLine: startLine, EndLine: endLine}
+
+	var path []noteHeading
+	for _, heading := range s.headings {
+		if heading.line > startLine {
+			break
+		}
+		for len(path) > 0 && path[len(path)-1].level >= heading.level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, heading)
+	}
+	for _, heading := range path {
+		anchor.HeadingPath = append(anchor.HeadingPath, heading.text)
+	}
+
+	for line := startLine; line <= endLine; line++ {
+		if id, ok := s.blockIDs[line]; ok {
+			anchor.BlockIDs = append(anchor.BlockIDs, id)
+		}
+	}
+
+	return anchor
+}
+
+// anchorChunks records for every chunk where it is in the note: start_line, end_line, heading_path,
+// the closest heading and block_ids, unless the chunker set them already. Task documents are
+// anchored at their task line.
+func (idx *ObsidianIndexer) anchorChunks(chunks []chroma.Document, source *sourceMap) {
+	from := 0
+	for i := range chunks {
+		var anchor chunkAnchor
+		if line, ok := chunks[i].Metadata["task_line"].(int); ok {
+			anchor = source.anchor(line, line)
+		} else {
+			var found bool
+			if anchor, from, found = source.locate(chunks[i].Content, from); !found {
+				continue
+			}
+		}
+
+		for key, value := range anchor.metadata() {
+			if _, ok := chunks[i].Metadata[key]; !ok { // Keep the breadcrumb chosen by the chunker
+				chunks[i].Metadata[key] = value
+			}
+		}
+	}
+}
+
+// metadata returns the anchor as chunk metadata
+func (a chunkAnchor) metadata() map[string]interface{} {
+	metadata := map[string]interface{}{
+		"start_line": a.StartLine,
+		"end_line":   a.EndLine,
+	}
+	if len(a.HeadingPath) > 0 {
+		metadata["heading_path"] = strings.Join(a.HeadingPath, " > ")
+		metadata["heading"] = a.HeadingPath[len(a.HeadingPath)-1]
+	}
+	if len(a.BlockIDs) > 0 {
+		metadata["block_ids"] = strings.Join(a.BlockIDs, ", ")
+	}
+	return metadata
+}
@@ -0,0 +1,78 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSourceMapAnchor tests heading paths and block IDs for a line range
+func TestSourceMapAnchor(t *testing.T) {
+	content := "---\ntitle: x\n---\n# Guide\n\nIntro ^intro\n\n## Setup\n\n```\n# not a heading ^code\n```\n" +
+		"### Linux\nInstall it. ^linux\n## Usage\nRun it."
+
+	source := (&ObsidianIndexer{}).newSourceMap(content)
+
+	anchor := source.anchor(14, 16)
+	assert.Equal(t, 14, anchor.StartLine)
+	assert.Equal(t, 16, anchor.EndLine)
+	assert.Equal(t, []string{"Guide", "Setup", "Linux"}, anchor.HeadingPath)
+	assert.Equal(t, []string{"linux"}, anchor.BlockIDs)
+
+	anchor = source.anchor(16, 16)
+	assert.Equal(t, []string{"Guide", "Usage"}, anchor.HeadingPath)
+	assert.Empty(t, anchor.BlockIDs)
+
+	anchor = source.anchor(1, 12)
+	assert.Empty(t, anchor.HeadingPath)
+	assert.Equal(t, []string{"intro"}, anchor.BlockIDs, "block IDs in code are ignored")
+}
+
+// TestChunkAnchors tests that indexed chunks record their lines, heading path and block IDs
+func TestChunkAnchors(t *testing.T) {
+	vaultDir := t.TempDir()
+	notePath := filepath.Join(vaultDir, "guide.md")
+
+	var body strings.Builder
+	for i := 0; i < 30; i++ {
+		body.WriteString("Sentence number " + strings.Repeat("x", i%7+1) + " explains [[Setup]] steps.\n")
+	}
+	content := "---\ntags: [guide]\n---\n# Guide\n\n## Setup\n\n" + body.String() + "The final line. ^end\n\n- [ ] Check the setup ^check\n"
+	require.NoError(t, os.WriteFile(notePath, []byte(content), 0644))
+
+	indexer := &ObsidianIndexer{vaultPath: vaultDir, chunkSize: 400, chunkOverlap: 50}
+	chunks, _, err := indexer.processFileWithChunks(notePath)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 3)
+
+	first := chunks[0].Metadata
+	assert.Equal(t, 4, first["start_line"])
+	assert.Equal(t, "Guide > Setup", first["heading_path"], "headings without content are kept with the next section")
+
+	previousStart := 0
+	for _, chunk := range chunks[:len(chunks)-1] {
+		start, ok := chunk.Metadata["start_line"].(int)
+		require.True(t, ok, "chunk %q has no start line", chunk.Content)
+		assert.GreaterOrEqual(t, start, previousStart)
+		assert.GreaterOrEqual(t, chunk.Metadata["end_line"].(int), start)
+		previousStart = start
+	}
+
+	last := chunks[len(chunks)-3]
+	assert.Contains(t, last.Content, "The final line.")
+	assert.Equal(t, 38, last.Metadata["end_line"])
+	assert.Equal(t, "end", last.Metadata["block_ids"])
+	assert.Equal(t, "Guide > Setup", last.Metadata["heading_path"])
+	assert.Equal(t, "Setup", last.Metadata["heading"])
+
+	task := chunks[len(chunks)-1].Metadata
+	assert.Equal(t, TaskChunkType, task["chunk_type"])
+	assert.Equal(t, 40, task["start_line"])
+	assert.Equal(t, 40, task["end_line"])
+	assert.Equal(t, "check", task["block_ids"])
+	assert.Equal(t, "Guide > Setup", task["heading_path"])
+}
@@ -0,0 +1,407 @@
+package indexer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"obsidian-ai-agent/internal/chroma"
+)
+
+// ChunkStrategy chooses how sections longer than the chunk size are split
+type ChunkStrategy string
+
+const (
+	// ChunkByParagraphs packs whole paragraphs into chunks, overlapping by trailing paragraphs up to
+	// ChunkOverlap; paragraphs longer than a chunk are cut by size
+	ChunkByParagraphs ChunkStrategy = "paragraphs"
+	// ChunkBySentences packs whole paragraphs and sentences into chunks, overlapping by
+	// OverlapSentences trailing sentences; only sentences longer than a chunk are cut by size
+	ChunkBySentences ChunkStrategy = "sentences"
+	// ChunkBySemantic embeds the sentences and cuts chunks where the topic shifts, without overlap
+	ChunkBySemantic ChunkStrategy = "semantic"
+	// ChunkByNote embeds a note that fits in a chunk whole, ignoring its headings; longer notes are
+	// chunked like ChunkByParagraphs
+	ChunkByNote ChunkStrategy = "note"
+)
+
+// ParseChunkStrategy parses a chunk strategy name; an empty name selects ChunkByParagraphs
+func ParseChunkStrategy(name string) (ChunkStrategy, error) {
+	switch strategy := ChunkStrategy(strings.ToLower(strings.TrimSpace(name))); strategy {
+	case "":
+		return ChunkByParagraphs, nil
+	case ChunkByParagraphs, ChunkBySentences, ChunkBySemantic, ChunkByNote:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("unknown chunk strategy %q (expected %s, %s, %s or %s)", name, ChunkByParagraphs, ChunkBySentences, ChunkBySemantic, ChunkByNote)
+	}
+}
+
+// thematicBreakRegex matches horizontal rules such as "---", "***" or "_ _ _"
+var thematicBreakRegex = regexp.MustCompile(`^\s{0,3}([-*_])(?:\s*([-*_])){2,}\s*$`)
+
+// markdownSection is a heading with the content up to the next heading
+type markdownSection struct {
+	Content     string   // Markdown of the section, starting with its heading unless it is the preamble
+	HeadingPath []string // Breadcrumb of the section's heading, outermost first; empty for the preamble
+}
+
+// chunkContent splits markdown content into chunks with the indexer's chunking settings
+func (idx *ObsidianIndexer) chunkContent(content string, filePath string) []chroma.Document {
+	return idx.chunkNote(content, filePath, idx.defaultChunkSettings())
+}
+
+// chunkNote splits markdown content into chunks along its headings and paragraphs. Boundaries
+// are chosen on the markdown; each chunk is cleaned afterwards.
+func (idx *ObsidianIndexer) chunkNote(content, filePath string, settings chunkSettings) []chroma.Document {
+	if settings.strategy == ChunkByNote {
+		if cleaned := idx.cleanContent(content); cleaned != "" && idx.textSize(cleaned) <= settings.size {
+			return []chroma.Document{newChunk(filePath, 0, cleaned, "note", nil)}
+		}
+	}
+
+	var chunks []chroma.Document
+
+	sections := idx.mergeShortSections(splitSections(content), settings.size)
+
+	for i, section := range sections {
+		cleaned := idx.cleanContent(section.Content)
+		if cleaned == "" {
+			continue
+		}
+
+		// If the section is too large, split it further along its paragraphs
+		if idx.textSize(cleaned) > settings.size {
+			// Continuations of the section start with its breadcrumb, which takes up room in the chunk
+			prefix, pieceSettings := "", settings
+			if settings.enrichment == EnrichHeadings && len(section.HeadingPath) > 0 {
+				prefix = idx.cleanContent(strings.Join(section.HeadingPath, " > "))
+				pieceSettings.size = max(settings.size-idx.textSize(prefix)-idx.separatorSize(), 1)
+			}
+
+			for j, piece := range idx.splitSection(section.Content, pieceSettings) {
+				if j > 0 && prefix != "" {
+					piece = prefix + " " + piece
+				}
+				chunkIndex := (i+1)*1000 + j // Kept apart from the indices of whole sections
+				chunks = append(chunks, newChunk(filePath, chunkIndex, piece, "sub_header", section.HeadingPath))
+			}
+			continue
+		}
+
+		chunks = append(chunks, newChunk(filePath, i, cleaned, "header", section.HeadingPath))
+	}
+
+	return chunks
+}
+
+// splitSection splits the markdown of a section that is too large for a single chunk
+func (idx *ObsidianIndexer) splitSection(content string, settings chunkSettings) []string {
+	switch settings.strategy {
+	case ChunkBySentences:
+		return idx.packSentences(splitBlocks(content), settings.size, settings.overlap, settings.overlapSentences)
+	case ChunkBySemantic:
+		return idx.packSemantic(splitBlocks(content), settings.size, settings.overlap)
+	default:
+		return idx.packBlocks(splitBlocks(content), settings.size, settings.overlap)
+	}
+}
+
+// mergeShortSections keeps headings without content, and a short preamble such as the frontmatter
+// summary, together with the section that follows them
+func (idx *ObsidianIndexer) mergeShortSections(sections []markdownSection, chunkSize int) []markdownSection {
+	var merged []markdownSection
+
+	for i := 0; i < len(sections); i++ {
+		section := sections[i]
+		preamble := i == 0 && len(section.HeadingPath) == 0
+		for i+1 < len(sections) && (preamble || headingOnly(sections[i].Content)) {
+			combined := section.Content + "\n\n" + sections[i+1].Content
+			if preamble && idx.textSize(idx.cleanContent(combined)) > chunkSize {
+				break
+			}
+			section = markdownSection{Content: combined, HeadingPath: sections[i+1].HeadingPath}
+			preamble = false
+			i++
+		}
+		merged = append(merged, section)
+	}
+
+	return merged
+}
+
+// headingOnly reports whether section markdown consists of nothing but its heading line
+func headingOnly(content string) bool {
+	return !strings.Contains(content, "\n") && headingPattern.MatchString(content)
+}
+
+// newChunk creates a chunk document with the metadata every chunk has
+func newChunk(filePath string, chunkIndex int, content, chunkType string, headingPath []string) chroma.Document {
+	metadata := map[string]interface{}{
+		"path":        filePath,
+		"filename":    filepath.Base(filePath),
+		"folder":      filepath.Dir(filePath),
+		"chunk_index": chunkIndex,
+		"chunk_type":  chunkType,
+	}
+	if len(headingPath) > 0 {
+		metadata["heading_path"] = strings.Join(headingPath, " > ")
+		metadata["heading"] = headingPath[len(headingPath)-1]
+	}
+
+	return chroma.Document{
+		ID:       generateChunkID(filePath, content, 0),
+		Content:  content,
+		Metadata: metadata,
+	}
+}
+
+// numberDuplicateChunks gives chunks of a note with the same text distinct IDs
+func numberDuplicateChunks(filePath string, chunks []chroma.Document) {
+	seen := make(map[string]int, len(chunks))
+	for i, chunk := range chunks {
+		if occurrence := seen[chunk.Content]; occurrence > 0 {
+			chunks[i].ID = generateChunkID(filePath, chunk.Content, occurrence)
+		}
+		seen[chunk.Content]++
+	}
+}
+
+// splitSections splits markdown at its headings, outside fenced code blocks, and records the
+// heading breadcrumb of every section
+func splitSections(content string) []markdownSection {
+	var sections []markdownSection
+	var path []noteHeading
+	var current []string
+
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			section := markdownSection{Content: text}
+			for _, heading := range path {
+				section.HeadingPath = append(section.HeadingPath, heading.text)
+			}
+			sections = append(sections, section)
+		}
+		current = nil
+	}
+
+	inFence := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inFence != "":
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			inFence = trimmed[:3]
+		default:
+			if match := headingPattern.FindStringSubmatch(line); match != nil {
+				flush()
+				level := len(match[1])
+				for len(path) > 0 && path[len(path)-1].level >= level {
+					path = path[:len(path)-1]
+				}
+				path = append(path, noteHeading{level: level, text: match[2]})
+			}
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return sections
+}
+
+// splitBlocks splits markdown into blocks separated by blank lines, keeping fenced code blocks
+// whole and dropping horizontal rules
+func splitBlocks(content string) []string {
+	var blocks []string
+	var current []string
+
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(current, "\n")); text != "" {
+			blocks = append(blocks, text)
+		}
+		current = nil
+	}
+
+	inFence := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inFence != "":
+			current = append(current, line)
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+				flush()
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			inFence = trimmed[:3]
+			current = append(current, line)
+		case trimmed == "":
+			flush()
+		case thematicBreakRegex.MatchString(line):
+			flush()
+		default:
+			current = append(current, line)
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// packBlocks cleans markdown blocks and packs as many whole blocks into each chunk as fit in
+// chunkSize. The next chunk repeats trailing blocks of the previous one up to overlap. Blocks larger
+// than chunkSize are split by size.
+func (idx *ObsidianIndexer) packBlocks(blocks []string, chunkSize, overlap int) []string {
+	var chunks []string
+	var current []string
+	var sizes []int       // textSize of the blocks in current
+	size, pending := 0, 0 // Size of current joined; number of blocks not in a chunk yet
+	separator := idx.separatorSize()
+
+	emit := func() {
+		if pending == 0 {
+			return
+		}
+		chunks = append(chunks, strings.Join(current, " "))
+		pending = 0
+
+		// Carry over trailing blocks, but never the whole chunk
+		carried, carriedSize := 0, 0
+		for k := len(current) - 1; k > 0; k-- {
+			if carriedSize+sizes[k]+separator > overlap {
+				break
+			}
+			carriedSize += sizes[k] + separator
+			carried++
+		}
+		current = append([]string(nil), current[len(current)-carried:]...)
+		sizes = append([]int(nil), sizes[len(sizes)-carried:]...)
+		size = max(carriedSize-separator, 0)
+	}
+
+	for _, block := range blocks {
+		cleaned := idx.cleanContent(block)
+		if cleaned == "" {
+			continue
+		}
+
+		blockSize := idx.textSize(cleaned)
+		if blockSize > chunkSize {
+			// Blocks waiting for a chunk, such as the section heading, lead the oversized block
+			if pending > 0 {
+				cleaned = strings.Join(append(current, cleaned), " ")
+			}
+			current, sizes, size, pending = nil, nil, 0, 0
+			chunks = append(chunks, idx.splitBySize(cleaned, chunkSize, overlap)...)
+			continue
+		}
+
+		if len(current) > 0 && size+separator+blockSize > chunkSize {
+			emit()
+			if size+separator+blockSize > chunkSize {
+				current, sizes, size = nil, nil, 0 // The carried over blocks do not fit next to this one
+			}
+		}
+		if len(current) > 0 {
+			size += separator
+		}
+		current = append(current, cleaned)
+		sizes = append(sizes, blockSize)
+		size += blockSize
+		pending++
+	}
+	emit()
+
+	return chunks
+}
+
+// splitBySize splits content into size-based chunks with overlap
+func (idx *ObsidianIndexer) splitBySize(content string, chunkSize, overlap int) []string {
+	if idx.tokenSizing {
+		return idx.splitByTokens(content, chunkSize, overlap)
+	}
+	if len(content) <= chunkSize {
+		return []string{content}
+	}
+
+	var chunks []string
+	start := 0
+
+	// Minimum meaningful chunk size to prevent tiny chunks at boundaries
+	minChunkSize := 50 // Minimum 50 characters for meaningful content
+
+	for start < len(content) {
+		end := start + chunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+
+		// Try to break at word boundary
+		if end < len(content) {
+			// Look for last space within reasonable distance
+			for i := end; i > end-100 && i > start; i-- {
+				if content[i] == ' ' || content[i] == '\n' {
+					end = i
+					break
+				}
+			}
+		}
+
+		chunk := strings.TrimSpace(content[start:end])
+		if len(chunk) > 0 {
+			chunks = append(chunks, chunk)
+		}
+
+		// Calculate next start position with overlap
+		nextStart := end - overlap
+
+		// Prevent cascading tiny chunks at document boundaries
+		if nextStart <= start {
+			// If overlap would cause us to go backwards or stay in place,
+			// check if the remaining content is small enough to append to current chunk
+			remainingContent := len(content) - end
+			if remainingContent <= minChunkSize && len(chunks) > 0 {
+				// Append remaining small content to the last chunk
+				remainingText := strings.TrimSpace(content[end:])
+				if len(remainingText) > 0 {
+					lastChunk := chunks[len(chunks)-1]
+					chunks[len(chunks)-1] = lastChunk + " " + remainingText
+				}
+				break
+			}
+			// Otherwise ensure we make minimal progress (but still prevent infinite loop)
+			nextStart = start + 1
+		}
+
+		// Additional check: if remaining content would create cascading tiny chunks, stop
+		remainingFromNext := len(content) - nextStart
+		if remainingFromNext <= minChunkSize {
+			// Append remaining content to the last chunk instead of creating tiny chunks
+			if len(chunks) > 0 && remainingFromNext > 0 {
+				lastChunk := chunks[len(chunks)-1]
+				remainingText := strings.TrimSpace(content[nextStart:])
+				if len(remainingText) > 0 {
+					// Only append if it's not already included (avoid duplication)
+					if !strings.HasSuffix(lastChunk, remainingText) {
+						chunks[len(chunks)-1] = lastChunk + " " + remainingText
+					}
+				}
+			}
+			break
+		}
+
+		start = nextStart
+
+		// Safety check: if we're at the end, break
+		if start >= len(content) {
+			break
+		}
+	}
+
+	return chunks
+}
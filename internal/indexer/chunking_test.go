@@ -10,13 +10,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// TestSplitByHeaders tests the header-based splitting functionality
-func TestSplitByHeaders(t *testing.T) {
-	indexer := &ObsidianIndexer{
-		chunkSize:    2000,
-		chunkOverlap: 200,
-	}
-
+// TestSplitSections tests the header-based splitting functionality
+func TestSplitSections(t *testing.T) {
 	tests := []struct {
 		name     string
 		content  string
@@ -84,27 +79,53 @@ More content`,
 				"## Chapter 2\n\nMore content",
 			},
 		},
+		{
+			name:    "headings in code blocks",
+			content: "# Setup\n\n```bash\n# install\nmake\n```\n\nDone.",
+			expected: []string{
+				"# Setup\n\n```bash\n# install\nmake\n```\n\nDone.",
+			},
+		},
 		{
 			name:     "empty content",
 			content:  "",
-			expected: []string{""},
+			expected: nil,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := indexer.splitByHeaders(tt.content)
+			result := splitSections(tt.content)
 
-			require.Len(t, result, len(tt.expected), "splitByHeaders() chunk count mismatch")
+			require.Len(t, result, len(tt.expected), "splitSections() chunk count mismatch")
 
-			for i, chunk := range result {
-				assert.Equal(t, strings.TrimSpace(tt.expected[i]), strings.TrimSpace(chunk),
+			for i, section := range result {
+				assert.Equal(t, strings.TrimSpace(tt.expected[i]), section.Content,
 					"chunk %d content mismatch", i)
 			}
 		})
 	}
 }
 
+// TestSplitSectionsHeadingPath tests the heading breadcrumb of each section
+func TestSplitSectionsHeadingPath(t *testing.T) {
+	content := "Preamble\n# Guide\n## Install\n### Linux\napt\n### macOS\nbrew\n## Usage\nRun\n# Appendix"
+
+	var paths []string
+	for _, section := range splitSections(content) {
+		paths = append(paths, strings.Join(section.HeadingPath, " > "))
+	}
+	assert.Equal(t, []string{
+		"",
+		"Guide",
+		"Guide > Install",
+		"Guide > Install > Linux",
+		"Guide > Install > macOS",
+		"Guide > Usage",
+		"Appendix",
+	}, paths)
+}
+
 // TestSplitBySize tests the size-based splitting with overlap
 func TestSplitBySize(t *testing.T) {
 	indexer := &ObsidianIndexer{
@@ -250,34 +271,34 @@ Short section.`,
 	}
 }
 
-// TestGenerateChunkID tests chunk ID generation
+// TestGenerateChunkID tests that chunk IDs are derived from the path and text of a chunk
 func TestGenerateChunkID(t *testing.T) {
 	tests := []struct {
-		name       string
-		filePath   string
-		chunkIndex int
+		name     string
+		filePath string
+		content  string
 	}{
 		{
-			name:       "simple path",
-			filePath:   "/test/file.md",
-			chunkIndex: 0,
+			name:     "simple path",
+			filePath: "/test/file.md",
+			content:  "Intro paragraph.",
 		},
 		{
-			name:       "complex path",
-			filePath:   "/Users/test/Documents/My Notes/file with spaces.md",
-			chunkIndex: 5,
+			name:     "complex path",
+			filePath: "/Users/test/Documents/My Notes/file with spaces.md",
+			content:  "Notes Some text.",
 		},
 		{
-			name:       "relative path",
-			filePath:   "./notes/test.md",
-			chunkIndex: 10,
+			name:     "relative path",
+			filePath: "./notes/test.md",
+			content:  "",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			id1 := generateChunkID(tt.filePath, tt.chunkIndex)
-			id2 := generateChunkID(tt.filePath, tt.chunkIndex)
+			id1 := generateChunkID(tt.filePath, tt.content, 0)
+			id2 := generateChunkID(tt.filePath, tt.content, 0)
 
 			// Should be consistent
 			assert.Equal(t, id1, id2, "generateChunkID() inconsistent")
@@ -285,13 +306,17 @@ func TestGenerateChunkID(t *testing.T) {
 			// Should be non-empty
 			assert.NotEmpty(t, id1, "generateChunkID() returned empty ID")
 
-			// Different indices should produce different IDs
-			id3 := generateChunkID(tt.filePath, tt.chunkIndex+1)
-			assert.NotEqual(t, id1, id3, "generateChunkID() same ID for different indices")
+			// Different text should produce different IDs
+			id3 := generateChunkID(tt.filePath, tt.content+" Edited.", 0)
+			assert.NotEqual(t, id1, id3, "generateChunkID() same ID for different text")
+
+			// Repeated text should produce different IDs
+			id4 := generateChunkID(tt.filePath, tt.content, 1)
+			assert.NotEqual(t, id1, id4, "generateChunkID() same ID for repeated text")
 
 			// Different paths should produce different IDs
-			id4 := generateChunkID(tt.filePath+"_different", tt.chunkIndex)
-			assert.NotEqual(t, id1, id4, "generateChunkID() same ID for different paths")
+			id5 := generateChunkID(tt.filePath+"_different", tt.content, 0)
+			assert.NotEqual(t, id1, id5, "generateChunkID() same ID for different paths")
 		})
 	}
 }
@@ -391,3 +416,43 @@ func BenchmarkChunkContent(b *testing.B) {
 		_ = indexer.chunkContent(content.String(), "/test/bench.md")
 	}
 }
+
+// TestProcessFileWithChunksKeepsStructure tests that headings still split notes after frontmatter
+// and markup are processed, and that each chunk records its heading breadcrumb
+func TestProcessFileWithChunksKeepsStructure(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "guide.md")
+
+	testContent := "---\ntags: [guide]\n---\n# Guide\n\n## Install\n\nDownload the [[Installer]].\n\n" +
+		"### Linux\n\nUse the package.\n\n```bash\n# not a heading\napt install guide\n```\n\n## Usage\n\nRun it."
+	require.NoError(t, os.WriteFile(testFile, []byte(testContent), 0644))
+
+	indexer := &ObsidianIndexer{chunkSize: 1000, chunkOverlap: 100}
+	chunks, _, err := indexer.processFileWithChunks(testFile)
+	require.NoError(t, err)
+	require.Len(t, chunks, 3)
+
+	assert.Equal(t, "Tags: guide. # Guide ## Install Download the Installer.", chunks[0].Content)
+	assert.Equal(t, "Guide > Install", chunks[0].Metadata["heading_path"])
+	assert.Equal(t, "Install", chunks[0].Metadata["heading"])
+
+	assert.Contains(t, chunks[1].Content, "# not a heading apt install guide")
+	assert.Equal(t, "Guide > Install > Linux", chunks[1].Metadata["heading_path"])
+
+	assert.Equal(t, "## Usage Run it.", chunks[2].Content)
+	assert.Equal(t, "Guide > Usage", chunks[2].Metadata["heading_path"])
+}
+
+// TestPackBlocks tests packing whole paragraphs into chunks with block overlap
+func TestPackBlocks(t *testing.T) {
+	indexer := &ObsidianIndexer{}
+	blocks := splitBlocks("First paragraph here.\n\nSecond one.\n\n---\n\nThird paragraph that is longer.\n\nFourth.")
+	require.Equal(t, []string{"First paragraph here.", "Second one.", "Third paragraph that is longer.", "Fourth."}, blocks)
+
+	chunks := indexer.packBlocks(blocks, 45, 15)
+	assert.Equal(t, []string{
+		"First paragraph here. Second one.",
+		"Second one. Third paragraph that is longer.",
+		"Fourth.",
+	}, chunks)
+}
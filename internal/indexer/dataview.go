@@ -0,0 +1,149 @@
+package indexer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"obsidian-ai-agent/internal/markdown"
+)
+
+var (
+	// lineFieldRegex matches a "key:: value" inline field at the start of a line or list item
+	lineFieldRegex = regexp.MustCompile(`^(\s*(?:[-*+]\s+(?:\[.\]\s+)?)?)(?:\*\*|__)?([\p{L}\p{N}_][\p{L}\p{N}_\- ]*?)(?:\*\*|__)?::\s*(.*)$`)
+
+	// bracketFieldRegex matches "[key:: value]" and "(key:: value)" inline fields anywhere in a line;
+	// values may contain wikilinks
+	bracketFieldRegex = regexp.MustCompile(`\[([\p{L}\p{N}_][\p{L}\p{N}_\- ]*?)::\s*((?:\[\[[^\]]*\]\]|[^\[\]])*?)\s*\]|\(([\p{L}\p{N}_][\p{L}\p{N}_\- ]*?)::\s*((?:\[\[[^\]]*\]\]|[^()\[\]])*?)\s*\)`)
+
+	fieldLinkRegex  = regexp.MustCompile(`^\[\[([^\]|#]+)(?:[#|][^\]]*)?\]\]$`)
+	queryFenceRegex = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w-]+)")
+)
+
+// inlineField is a Dataview inline field
+type inlineField struct {
+	Key   string // Canonical key, e.g. "due-date" for "Due Date"
+	Value string // Raw value text
+}
+
+// canonicalFieldKey normalises a field name like Dataview does: lowercase, spaces as "-"
+func canonicalFieldKey(key string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strings.Trim(key, "*_ ")), "-"))
+}
+
+// extractInlineFields returns the Dataview inline fields of a note body, outside fenced code
+// blocks and inline code
+func extractInlineFields(body string) []inlineField {
+	var fields []inlineField
+
+	for _, line := range proseLines(body) {
+		for _, match := range bracketFieldRegex.FindAllStringSubmatch(line, -1) {
+			key, value := match[1], match[2]
+			if key == "" {
+				key, value = match[3], match[4]
+			}
+			fields = append(fields, inlineField{Key: canonicalFieldKey(key), Value: strings.TrimSpace(value)})
+		}
+
+		if match := lineFieldRegex.FindStringSubmatch(line); match != nil {
+			fields = append(fields, inlineField{Key: canonicalFieldKey(match[2]), Value: strings.TrimSpace(match[3])})
+		}
+	}
+
+	return fields
+}
+
+// inlineFieldMetadata converts inline fields to typed metadata values. Repeated keys are
+// collected in a list, like Dataview does.
+func inlineFieldMetadata(fields []inlineField) map[string]interface{} {
+	metadata := make(map[string]interface{})
+
+	for _, field := range fields {
+		if field.Key == "" || field.Value == "" {
+			continue
+		}
+		value := parseFieldValue(field.Value)
+
+		existing, ok := metadata[field.Key]
+		if !ok {
+			metadata[field.Key] = value
+			continue
+		}
+		list, isList := existing.([]string)
+		if !isList {
+			list = []string{frontmatterString(existing)}
+		}
+		metadata[field.Key] = append(list, frontmatterString(value))
+	}
+
+	return metadata
+}
+
+// parseFieldValue types an inline field value: booleans, numbers and dates keep their type,
+// a single wikilink becomes its target, anything else stays text
+func parseFieldValue(value string) interface{} {
+	switch strings.ToLower(value) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return int(number)
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil {
+		return number
+	}
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339} {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date
+		}
+	}
+	if match := fieldLinkRegex.FindStringSubmatch(value); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+
+	return value
+}
+
+// extractQueryBlocks returns the sources of dataview, dataviewjs and tasks code blocks by language
+func extractQueryBlocks(body string) map[string][]string {
+	queries := make(map[string][]string)
+
+	lines := strings.Split(body, "\n")
+	for i := 0; i < len(lines); i++ {
+		match := queryFenceRegex.FindStringSubmatch(lines[i])
+		if match == nil {
+			continue
+		}
+
+		// Find the closing fence, also for code blocks that are not queries
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), match[1]) {
+			end++
+		}
+
+		language := strings.ToLower(match[2])
+		for _, queryLanguage := range markdown.QueryLanguages {
+			if language == queryLanguage {
+				if source := strings.TrimSpace(strings.Join(lines[i+1:min(end, len(lines))], "\n")); source != "" {
+					queries[language] = append(queries[language], source)
+				}
+			}
+		}
+		i = end
+	}
+
+	return queries
+}
+
+// queryMetadata returns the recorded query sources as "query_<language>" metadata
+func queryMetadata(queries map[string][]string) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	for language, sources := range queries {
+		metadata["query_"+language] = strings.Join(sources, "\n\n")
+	}
+	return metadata
+}
@@ -0,0 +1,98 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExtractInlineFields tests the line and bracket forms of Dataview inline fields
+func TestExtractInlineFields(t *testing.T) {
+	body := "Status:: active\n" +
+		"**Client Name**:: [[Acme Corp|Acme]]\n" +
+		"- rating:: 4.5\n" +
+		"Met on [met:: 2025-03-01] and (mood:: good) today.\n" +
+		"`code:: not a field`\n" +
+		"```\nhidden:: in code\n```\n" +
+		"Tag:: one\nTag:: two"
+
+	fields := extractInlineFields(body)
+	assert.Equal(t, []inlineField{
+		{Key: "status", Value: "active"},
+		{Key: "client-name", Value: "[[Acme Corp|Acme]]"},
+		{Key: "rating", Value: "4.5"},
+		{Key: "met", Value: "2025-03-01"},
+		{Key: "mood", Value: "good"},
+		{Key: "tag", Value: "one"},
+		{Key: "tag", Value: "two"},
+	}, fields)
+
+	metadata := inlineFieldMetadata(fields)
+	assert.Equal(t, map[string]interface{}{
+		"status":      "active",
+		"client-name": "Acme Corp",
+		"rating":      4.5,
+		"met":         time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		"mood":        "good",
+		"tag":         []string{"one", "two"},
+	}, metadata)
+}
+
+// TestParseFieldValue tests typing of inline field values
+func TestParseFieldValue(t *testing.T) {
+	assert.Equal(t, true, parseFieldValue("true"))
+	assert.Equal(t, 42, parseFieldValue("42"))
+	assert.Equal(t, -1.5, parseFieldValue("-1.5"))
+	assert.Equal(t, time.Date(2025, 1, 2, 9, 30, 0, 0, time.UTC), parseFieldValue("2025-01-02T09:30"))
+	assert.Equal(t, "Note", parseFieldValue("[[Note#Heading]]"))
+	assert.Equal(t, "[[A]], [[B]]", parseFieldValue("[[A]], [[B]]"))
+	assert.Equal(t, "in progress", parseFieldValue("in progress"))
+}
+
+// TestExtractQueryBlocks tests recording of query sources
+func TestExtractQueryBlocks(t *testing.T) {
+	body := "Intro\n```dataview\nTABLE rating\nFROM #book\n```\n```go\nfmt.Println()\n```\n" +
+		"```dataviewjs\ndv.list([1])\n```\n```tasks\nnot done\n```\n```dataview\nLIST\n```"
+
+	assert.Equal(t, map[string][]string{
+		"dataview":   {"TABLE rating\nFROM #book", "LIST"},
+		"dataviewjs": {"dv.list([1])"},
+		"tasks":      {"not done"},
+	}, extractQueryBlocks(body))
+}
+
+// TestDataviewChunkMetadata tests inline fields and recorded queries on indexed chunks
+func TestDataviewChunkMetadata(t *testing.T) {
+	vaultDir := t.TempDir()
+	notePath := filepath.Join(vaultDir, "project.md")
+	content := "---\nstatus: planned\n---\n# Project\n\nstatus:: active\nclient:: [[Acme]]\nrating:: 4\n\n" +
+		"```dataview\nLIST FROM [[Acme]]\n```\n\n- [ ] Send invoice [due:: 2025-11-01] [priority:: high]\n"
+	require.NoError(t, os.WriteFile(notePath, []byte(content), 0644))
+
+	indexer := &ObsidianIndexer{vaultPath: vaultDir, chunkSize: 1000, chunkOverlap: 100}
+	chunks, _, err := indexer.processFileWithChunks(notePath)
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+
+	note := chunks[0]
+	assert.Equal(t, "planned", note.Metadata["status"], "frontmatter takes precedence")
+	assert.Equal(t, "Acme", note.Metadata["client"])
+	assert.Equal(t, 4, note.Metadata["rating"])
+	assert.NotContains(t, note.Metadata, "query_dataview", "queries are only recorded when enabled")
+	assert.NotContains(t, note.Content, "LIST FROM")
+	assert.Contains(t, note.Content, "client: Acme")
+
+	task := chunks[1].Metadata
+	assert.Equal(t, time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC).Unix(), task["task_due"])
+	assert.Equal(t, "high", task["task_priority"])
+	assert.Equal(t, "Send invoice", task["task_text"])
+
+	indexer.recordQueries = true
+	chunks, _, err = indexer.processFileWithChunks(notePath)
+	require.NoError(t, err)
+	assert.Equal(t, "LIST FROM [[Acme]]", chunks[0].Metadata["query_dataview"])
+}
@@ -0,0 +1,225 @@
+package indexer
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// reservedMetadataKeys are set by the indexer; frontmatter keys with the same name are stored
+// with frontmatterKeyPrefix so they cannot break path-based bookkeeping or be overwritten
+var reservedMetadataKeys = map[string]bool{
+	"path":               true,
+	"filename":           true,
+	"folder":             true,
+	"chunk_index":        true,
+	"chunk_type":         true,
+	"chunk_profile":      true,
+	"last_modified":      true,
+	"content_hash":       true,
+	"normalizer_version": true,
+	"chunk_settings":     true,
+	"callouts":           true,
+	"callout_titles":     true,
+	"highlights":         true,
+	"has_highlights":     true,
+	"heading":            true,
+	"heading_path":       true,
+	"start_line":         true,
+	"end_line":           true,
+	"block_ids":          true,
+	"task_status":        true,
+	"task_text":          true,
+	"task_line":          true,
+	"task_priority":      true,
+	"task_due":           true,
+	"task_scheduled":     true,
+	"task_start":         true,
+	"task_done":          true,
+	"task_created":       true,
+	"task_cancelled":     true,
+	"query_dataview":     true,
+	"query_dataviewjs":   true,
+	"query_tasks":        true,
+}
+
+const frontmatterKeyPrefix = "frontmatter_"
+
+// listFrontmatterKeys are always stored as lists, even when written as a single value
+var listFrontmatterKeys = map[string]bool{
+	"tags":       true,
+	"aliases":    true,
+	"categories": true,
+}
+
+// splitYAMLFrontmatter splits a note starting with a "---" line into its YAML block and body.
+// It reports false if the note has no YAML frontmatter.
+func splitYAMLFrontmatter(content string) (string, string, bool) {
+	lines := strings.Split(content, "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[0]) != "---" {
+		return "", content, false
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+			yamlBlock := strings.Join(lines[1:i], "\n")
+			body := strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+			return yamlBlock, body, true
+		}
+	}
+
+	return "", content, false
+}
+
+// parseYAMLFrontmatter decodes a YAML frontmatter block into flat, typed metadata:
+// nested maps become dotted keys, lists become []string and dates stay time.Time
+func (idx *ObsidianIndexer) parseYAMLFrontmatter(yamlBlock string) (map[string]interface{}, error) {
+	frontmatter := make(map[string]interface{})
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal([]byte(yamlBlock), &raw); err != nil {
+		return frontmatter, err
+	}
+
+	flattenFrontmatter("", raw, frontmatter)
+
+	// Normalise the keys Obsidian treats as lists
+	for key := range listFrontmatterKeys {
+		value, ok := frontmatter[key]
+		if !ok {
+			continue
+		}
+		list := frontmatterList(value)
+		switch key {
+		case "categories":
+			list = idx.normalizeCategories(list)
+		case "tags":
+			// Obsidian also accepts space-separated tags
+			list = normalizeTags(strings.Fields(strings.Join(list, " ")))
+		}
+		if len(list) == 0 {
+			delete(frontmatter, key)
+			continue
+		}
+		frontmatter[key] = list
+	}
+
+	return frontmatter, nil
+}
+
+// flattenFrontmatter copies YAML values into target, joining nested map keys with "."
+func flattenFrontmatter(prefix string, values map[string]interface{}, target map[string]interface{}) {
+	for key, value := range values {
+		key = strings.TrimSpace(key)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case nil:
+			continue // Empty values carry no information
+		case map[string]interface{}:
+			flattenFrontmatter(key, v, target)
+		case []interface{}:
+			target[key] = frontmatterList(v)
+		default:
+			target[key] = v
+		}
+	}
+}
+
+// frontmatterList converts a YAML list or a comma-separated string to a list of strings
+func frontmatterList(value interface{}) []string {
+	var list []string
+
+	switch v := value.(type) {
+	case []string:
+		list = v
+	case []interface{}:
+		for _, item := range v {
+			if item == nil {
+				continue
+			}
+			if nested, ok := item.(map[string]interface{}); ok {
+				// Render nested maps deterministically rather than dropping them
+				keys := make([]string, 0, len(nested))
+				for key := range nested {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					list = append(list, fmt.Sprintf("%s: %v", key, nested[key]))
+				}
+				continue
+			}
+			list = append(list, frontmatterString(item))
+		}
+	case string:
+		list = strings.Split(v, ",")
+	default:
+		list = []string{frontmatterString(v)}
+	}
+
+	var cleaned []string
+	for _, item := range list {
+		if item = strings.TrimSpace(item); item != "" {
+			cleaned = append(cleaned, item)
+		}
+	}
+
+	return cleaned
+}
+
+// frontmatterString renders a scalar frontmatter value as text
+func frontmatterString(value interface{}) string {
+	if date, ok := value.(time.Time); ok {
+		if date.Hour() == 0 && date.Minute() == 0 && date.Second() == 0 {
+			return date.Format("2006-01-02")
+		}
+		return date.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// normalizeCategories accepts both plain names and [[Category]] links
+func (idx *ObsidianIndexer) normalizeCategories(values []string) []string {
+	var categories []string
+	for _, value := range values {
+		if strings.Contains(value, "[[") {
+			categories = append(categories, idx.parseCategories(value)...)
+			continue
+		}
+		categories = append(categories, value)
+	}
+	return categories
+}
+
+// extractYAMLFrontmatter parses standard "---" delimited YAML frontmatter. It reports false
+// if the note does not start with a YAML block.
+func (idx *ObsidianIndexer) extractYAMLFrontmatter(content string) (map[string]interface{}, string, bool) {
+	yamlBlock, body, ok := splitYAMLFrontmatter(content)
+	if !ok {
+		return nil, content, false
+	}
+
+	frontmatter, err := idx.parseYAMLFrontmatter(yamlBlock)
+	if err != nil {
+		// Keep the YAML out of the embedded text even if it cannot be parsed
+		log.Printf("Warning: failed to parse YAML frontmatter: %v", err)
+		return make(map[string]interface{}), body, true
+	}
+
+	return frontmatter, body, true
+}
+
+// metadataKey returns the chunk metadata key for a frontmatter key
+func metadataKey(key string) string {
+	if reservedMetadataKeys[key] {
+		return frontmatterKeyPrefix + key
+	}
+	return key
+}
@@ -1,9 +1,13 @@
 package indexer
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestFrontmatterExtraction tests the extraction and parsing of YAML frontmatter
@@ -68,6 +72,18 @@ No separator line.`,
 			expectedMatter: map[string]interface{}{},
 			expectedBody:   "202508181928\nCategories: [[Software]]\n# Content immediately follows\n\nNo separator line.",
 		},
+		{
+			name:           "thematic break without header",
+			content:        "Some prose before the break.\n\n---\n\nMore prose after it.",
+			expectedMatter: map[string]interface{}{},
+			expectedBody:   "Some prose before the break.\n\n---\n\nMore prose after it.",
+		},
+		{
+			name:           "thematic break after a tags line in prose",
+			content:        "202508181928\nTags: work\nA sentence that is not a header.\n---\nBody",
+			expectedMatter: map[string]interface{}{},
+			expectedBody:   "202508181928\nTags: work\nA sentence that is not a header.\n---\nBody",
+		},
 		{
 			name: "malformed categories",
 			content: `202508181928
@@ -97,6 +113,64 @@ Tags:
 			},
 			expectedBody: "# Content",
 		},
+		{
+			name: "yaml frontmatter with typed values",
+			content: `---
+title: Hiring plan
+rating: 4
+score: 7.5
+draft: false
+created: 2024-03-01
+aliases: [Recruiting, "Hiring 2024"]
+tags:
+  - project/alpha
+  - meeting
+categories:
+  - "[[Management]]"
+  - People
+project:
+  client: Acme
+  budget: 12000
+empty:
+---
+
+# Plan
+
+Body text.`,
+			expectedMatter: map[string]interface{}{
+				"title":          "Hiring plan",
+				"rating":         4,
+				"score":          7.5,
+				"draft":          false,
+				"created":        time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				"aliases":        []string{"Recruiting", "Hiring 2024"},
+				"tags":           []string{"project/alpha", "meeting"},
+				"categories":     []string{"Management", "People"},
+				"project.client": "Acme",
+				"project.budget": 12000,
+			},
+			expectedBody: "# Plan\n\nBody text.",
+		},
+		{
+			name: "yaml frontmatter with single tag string",
+			content: `---
+tags: journal, daily
+---
+Body`,
+			expectedMatter: map[string]interface{}{
+				"tags": []string{"journal", "daily"},
+			},
+			expectedBody: "Body",
+		},
+		{
+			name: "invalid yaml frontmatter is dropped",
+			content: `---
+title: [unclosed
+---
+Body`,
+			expectedMatter: map[string]interface{}{},
+			expectedBody:   "Body",
+		},
 	}
 
 	for _, tt := range tests {
@@ -153,6 +227,14 @@ func TestFrontmatterToContent(t *testing.T) {
 			},
 			expectedText: "This document covers AI topics.",
 		},
+		{
+			name: "tags and aliases",
+			frontmatter: map[string]interface{}{
+				"tags":    []string{"hiring"},
+				"aliases": []string{"Recruiting", "Hiring 2024"},
+			},
+			expectedText: "Tags: hiring. Also known as: Recruiting, Hiring 2024.",
+		},
 	}
 
 	for _, tt := range tests {
@@ -205,7 +287,7 @@ func TestExtractFolderCategories(t *testing.T) {
 		{
 			name:               "file outside configured directories",
 			filePath:           "/home/user/vault/Other/subfolder/file.md",
-			expectedCategories: []string{},
+			expectedCategories: []string{"Other", "subfolder"},
 		},
 		{
 			name:               "relative path from within vault",
@@ -339,6 +421,31 @@ func TestConvertMetadataValue(t *testing.T) {
 			input:    true,
 			expected: true,
 		},
+		{
+			name:     "float unchanged",
+			input:    7.5,
+			expected: 7.5,
+		},
+		{
+			name:     "date to unix timestamp",
+			input:    time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			expected: int64(1709251200),
+		},
+		{
+			name:     "unsigned integer to int64",
+			input:    uint64(42),
+			expected: int64(42),
+		},
+		{
+			name:     "unsigned integer beyond int64 to string",
+			input:    uint64(18446744073709551615),
+			expected: "18446744073709551615",
+		},
+		{
+			name:     "mixed list to comma-separated string",
+			input:    []interface{}{"a", 1, true},
+			expected: "a, 1, true",
+		},
 	}
 
 	for _, tt := range tests {
@@ -348,3 +455,45 @@ func TestConvertMetadataValue(t *testing.T) {
 		})
 	}
 }
+
+// TestYAMLFrontmatterMetadata tests that typed frontmatter values reach the chunk metadata
+func TestYAMLFrontmatterMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "plan.md")
+	content := `---
+title: Hiring plan
+path: somewhere/else
+rating: 4
+published: true
+due: 2024-03-01
+tags: [hiring, people]
+---
+
+# Plan
+
+We need to hire two engineers this quarter.`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	indexer := &ObsidianIndexer{
+		vaultPath:    tempDir,
+		chunkSize:    2000,
+		chunkOverlap: 200,
+	}
+
+	chunks, _, err := indexer.processFileWithChunks(testFile)
+	require.NoError(t, err)
+	require.NotEmpty(t, chunks)
+
+	metadata := chunks[0].Metadata
+	assert.Equal(t, "Hiring plan", metadata["title"])
+	assert.Equal(t, 4, metadata["rating"])
+	assert.Equal(t, true, metadata["published"])
+	assert.Equal(t, int64(1709251200), metadata["due"])
+	assert.Equal(t, "hiring, people", metadata["tags"])
+
+	// Frontmatter cannot overwrite the indexer's own metadata
+	assert.Equal(t, testFile, metadata["path"])
+	assert.Equal(t, "somewhere/else", metadata["frontmatter_path"])
+
+	assert.NotContains(t, chunks[0].Content, "title:")
+}
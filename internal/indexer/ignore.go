@@ -0,0 +1,237 @@
+package indexer
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileName is the name of the ignore file read from the vault root
+const IgnoreFileName = ".chromaignore"
+
+// defaultIgnorePatterns are applied before the patterns of the ignore file, which can negate them
+var defaultIgnorePatterns = []string{
+	".obsidian/",
+	".trash/",
+	".git/",
+}
+
+// ignoreRule is a single compiled gitignore-style pattern
+type ignoreRule struct {
+	pattern string
+	regex   *regexp.Regexp
+	negate  bool // Pattern started with "!" and re-includes matching paths
+	dirOnly bool // Pattern ended with "/" and only matches directories
+}
+
+// ignoreRules is an ordered list of ignore patterns; the last matching rule wins
+type ignoreRules []ignoreRule
+
+// parseIgnoreRules compiles gitignore-style patterns. Blank lines and comments are skipped.
+func parseIgnoreRules(lines []string) (ignoreRules, error) {
+	var rules ignoreRules
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{pattern: line}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A slash at the start or in the middle anchors the pattern to the vault root;
+		// otherwise it matches a name at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		expr, err := globToRegexp(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", rule.pattern, err)
+		}
+		if anchored {
+			expr = "^" + expr + "$"
+		} else {
+			expr = "^(?:.*/)?" + expr + "$"
+		}
+
+		rule.regex, err = regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", rule.pattern, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// globToRegexp translates a gitignore glob into a regular expression over slash-separated paths
+func globToRegexp(glob string) (string, error) {
+	var expr strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			expr.WriteString("(?:.*/)?") // Zero or more directories
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString(".*") // Everything below
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated character class")
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			expr.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return expr.String(), nil
+}
+
+// match reports whether a single vault-relative path is ignored, not considering its parents
+func (rules ignoreRules) match(relPath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.regex.MatchString(relPath) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// ignored reports whether a vault-relative path or any of its parent directories is ignored.
+// As in git, a file cannot be re-included when one of its parent directories is ignored.
+func (rules ignoreRules) ignored(relPath string, isDir bool) bool {
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if rules.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return rules.match(relPath, isDir)
+}
+
+// loadIgnoreRules reads the default patterns and the vault's ignore file. It is called at the
+// start of every run, so edits to the ignore file take effect on the next scan.
+func (idx *ObsidianIndexer) loadIgnoreRules() {
+	lines := append([]string{}, defaultIgnorePatterns...)
+
+	ignoreFile := filepath.Join(idx.vaultPath, IgnoreFileName)
+	if file, err := os.Open(ignoreFile); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			log.Printf("Warning: failed to read %s: %v", ignoreFile, err)
+		}
+		file.Close()
+	} else if !os.IsNotExist(err) {
+		log.Printf("Warning: failed to open %s: %v", ignoreFile, err)
+	}
+
+	rules, err := parseIgnoreRules(lines)
+	if err != nil {
+		// Keep the previous rules rather than indexing (or purging) everything on a typo
+		log.Printf("Warning: not applying %s: %v", ignoreFile, err)
+		return
+	}
+	idx.ignoreMu.Lock()
+	idx.ignoreRules = rules
+	idx.ignoreMu.Unlock()
+}
+
+// isIgnored reports whether a path is excluded by the ignore rules
+func (idx *ObsidianIndexer) isIgnored(path string, isDir bool) bool {
+	idx.ignoreMu.RLock()
+	rules := idx.ignoreRules
+	idx.ignoreMu.RUnlock()
+
+	if len(rules) == 0 {
+		return false
+	}
+
+	relPath, err := vaultRelativePath(idx.vaultPath, path)
+	if err != nil || relPath == "." {
+		return false
+	}
+
+	return rules.ignored(relPath, isDir)
+}
+
+// IsIgnoredDir reports whether a directory is excluded by the ignore rules of the last run, so
+// that the filesystem watcher does not watch it
+func (idx *ObsidianIndexer) IsIgnoredDir(path string) bool {
+	return idx.isIgnored(path, true)
+}
+
+// optOutTag is the tag that excludes a note from indexing
+const optOutTag = "noindex"
+
+// optsOutOfIndexing reports whether a note excludes itself from indexing with "index: false"
+// or a "noindex" tag in its frontmatter
+func optsOutOfIndexing(frontmatter map[string]interface{}) bool {
+	switch index := frontmatter["index"].(type) {
+	case bool:
+		if !index {
+			return true
+		}
+	case string:
+		switch strings.ToLower(strings.Trim(strings.TrimSpace(index), `"'`)) {
+		case "false", "no", "off":
+			return true
+		}
+	}
+
+	if tags, ok := frontmatter["tags"].([]string); ok {
+		for _, tag := range tags {
+			if isOptOutTag(tag) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isOptOutTag reports whether a tag, with or without "#" and quotes, is the opt-out tag
+func isOptOutTag(tag string) bool {
+	tag = strings.Trim(strings.TrimSpace(tag), `"'`)
+	return strings.EqualFold(strings.TrimPrefix(tag, "#"), optOutTag)
+}
@@ -0,0 +1,188 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestIgnoreRules tests gitignore semantics of the ignore patterns
+func TestIgnoreRules(t *testing.T) {
+	rules, err := parseIgnoreRules([]string{
+		"# Comment",
+		"",
+		".obsidian/",
+		"Templates/",
+		"*.excalidraw.md",
+		"/Inbox.md",
+		"Archive/**/draft-*.md",
+		"Exports/*.md",
+		"!Exports/keep.md",
+		`\#literal.md`,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{".obsidian", true, true},
+		{".obsidian/workspace.md", false, true},
+		{"Projects/.obsidian/plugin.md", false, true},
+		{"Templates/daily.md", false, true},
+		{"Notes/Templates/meeting.md", false, true},
+		{"Notes/Templates.md", false, false}, // Directory pattern does not match files
+		{"Drawings/sketch.excalidraw.md", false, true},
+		{"Inbox.md", false, true},
+		{"Notes/Inbox.md", false, false}, // Anchored to the vault root
+		{"Archive/draft-1.md", false, true},
+		{"Archive/2023/q1/draft-2.md", false, true},
+		{"Archive/2023/final.md", false, false},
+		{"Exports/report.md", false, true},
+		{"Exports/keep.md", false, false}, // Negated
+		{"Exports/sub/report.md", false, false},
+		{"#literal.md", false, true},
+		{"Notes/idea.md", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.ignored, rules.ignored(tt.path, tt.isDir))
+		})
+	}
+}
+
+// TestIgnoredParentCannotBeReincluded tests that negation does not re-include files of ignored directories
+func TestIgnoredParentCannotBeReincluded(t *testing.T) {
+	rules, err := parseIgnoreRules([]string{"Templates/", "!Templates/keep.md"})
+	require.NoError(t, err)
+
+	assert.True(t, rules.ignored("Templates/keep.md", false))
+}
+
+// TestOptsOutOfIndexing tests the per-note opt-out in frontmatter and legacy headers
+func TestOptsOutOfIndexing(t *testing.T) {
+	indexer := &ObsidianIndexer{}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{"yaml index false", "---\ntitle: Secret\nindex: false\n---\n# Body", true},
+		{"yaml index true", "---\nindex: true\n---\n# Body", false},
+		{"yaml inline tags", "---\ntags: [journal, noindex]\n---\n# Body", true},
+		{"yaml tag list", "---\ntags:\n  - journal\n  - \"#noindex\"\n---\n# Body", true},
+		{"yaml other list", "---\ntags:\n  - journal\naliases:\n  - noindex\n---\n# Body", false},
+		{"legacy header", "20240101\nTags: #private, #noindex\n---\n# Body", true},
+		{"legacy index no", "20240101\nIndex: no\n---\n# Body", true},
+		{"body mention", "# Body\n\nSet index: false to hide notes.", false},
+		{"no header", "# Body\n\nJust content.", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frontmatter, _ := indexer.extractFrontmatter(tt.content)
+			assert.Equal(t, tt.expected, optsOutOfIndexing(frontmatter))
+		})
+	}
+}
+
+// newIgnoreTestVault creates a vault with the given notes and an indexer over the whole vault
+func newIgnoreTestVault(t *testing.T, notes map[string]string) (string, *ObsidianIndexer, *MockChromaClient) {
+	t.Helper()
+
+	vaultDir := t.TempDir()
+	for relPath, content := range notes {
+		path := filepath.Join(vaultDir, filepath.FromSlash(relPath))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	mockClient := NewMockChromaClient()
+	indexer := NewObsidianIndexer(mockClient, &Config{
+		VaultPath:    vaultDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	})
+
+	return vaultDir, indexer, mockClient
+}
+
+func TestIgnoredFilesAreNotIndexed(t *testing.T) {
+	content := "# Note\n\nContent that is long enough to be indexed."
+	vaultDir, indexer, mockClient := newIgnoreTestVault(t, map[string]string{
+		"notes/idea.md":            content,
+		".obsidian/plugin.md":      content,
+		".trash/old.md":            content,
+		"Templates/daily.md":       content,
+		"notes/private.md":         "---\nindex: false\n---\n# Private\n\nNothing to see here.",
+		"notes/export.dataview.md": content,
+		IgnoreFileName:             "Templates/\n*.dataview.md\n",
+	})
+
+	result, err := indexer.ReindexVault(context.Background(), []string{"."})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.ProcessedFiles)
+	assert.Equal(t, 1, result.IndexedFiles)
+	assert.Equal(t, 1, result.IgnoredFiles)
+	assert.Equal(t, 1, mockClient.GetUpsertCallCount())
+	assert.Len(t, indexer.fileIndex, 1)
+	assert.Contains(t, indexer.fileIndex, filepath.Join(vaultDir, "notes", "idea.md"))
+
+	// The watcher skips the same directories
+	assert.True(t, indexer.IsIgnoredDir(filepath.Join(vaultDir, "Templates")))
+	assert.True(t, indexer.IsIgnoredDir(filepath.Join(vaultDir, ".git")))
+	assert.False(t, indexer.IsIgnoredDir(filepath.Join(vaultDir, "notes")))
+	assert.False(t, indexer.IsIgnoredDir(vaultDir))
+}
+
+func TestNewlyIgnoredFilesArePurged(t *testing.T) {
+	content := "# Note\n\nContent that is long enough to be indexed."
+	vaultDir, indexer, mockClient := newIgnoreTestVault(t, map[string]string{
+		"notes/idea.md":    content,
+		"Archive/old.md":   content,
+		"notes/journal.md": "# Journal\n\nPrivate thoughts that were indexed before.",
+	})
+	ctx := context.Background()
+
+	_, err := indexer.ReindexVault(ctx, []string{"."})
+	require.NoError(t, err)
+	require.Len(t, indexer.fileIndex, 3)
+
+	archived := filepath.Join(vaultDir, "Archive", "old.md")
+	journal := filepath.Join(vaultDir, "notes", "journal.md")
+	archivedIDs := indexer.fileIndex[archived].ChunkIDs
+	journalIDs := indexer.fileIndex[journal].ChunkIDs
+
+	// Ignore a folder and opt a note out
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, os.WriteFile(filepath.Join(vaultDir, IgnoreFileName), []byte("Archive/\n"), 0644))
+	require.NoError(t, os.WriteFile(journal, []byte("---\ntags: [noindex]\n---\n# Journal\n\nPrivate thoughts."), 0644))
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.DeletedFiles)
+	assert.Equal(t, 1, result.IgnoredFiles)
+	assert.ElementsMatch(t, append(append([]string{}, archivedIDs...), journalIDs...), mockClient.GetDeletedIDs())
+	assert.NotContains(t, indexer.fileIndex, archived)
+	assert.NotContains(t, indexer.fileIndex, journal)
+
+	// Watched changes to ignored files do not bring them back
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, os.WriteFile(archived, []byte("# Note\n\nEdited content of an archived note."), 0644))
+	result, err = indexer.IndexFiles(ctx, []string{archived})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ProcessedFiles)
+	assert.NotContains(t, indexer.fileIndex, archived)
+}
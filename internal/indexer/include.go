@@ -0,0 +1,186 @@
+package indexer
+
+import (
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultIncludePattern indexes every note in the vault
+const defaultIncludePattern = "**/*.md"
+
+// pathPattern is a compiled glob over slash-separated vault-relative paths
+type pathPattern struct {
+	glob  string
+	root  string // Directory part before the first wildcard, "" for the vault root
+	regex *regexp.Regexp
+}
+
+// pathFilter selects the notes to index with include and exclude globs relative to the vault root
+type pathFilter struct {
+	include []pathPattern
+	exclude []pathPattern
+}
+
+// directoryPatterns expresses a list of vault directories as include patterns
+func directoryPatterns(directories []string) []string {
+	patterns := make([]string, 0, len(directories))
+	for _, dir := range directories {
+		dir = strings.Trim(filepath.ToSlash(filepath.Clean(strings.TrimSpace(dir))), "/")
+		if dir == "" || dir == "." {
+			patterns = append(patterns, defaultIncludePattern)
+			continue
+		}
+		patterns = append(patterns, dir+"/"+defaultIncludePattern)
+	}
+	return patterns
+}
+
+// newPathFilter compiles include and exclude globs. Include patterns starting with "!" are
+// treated as excludes. Without include patterns, every note in the vault is included.
+func newPathFilter(include, exclude []string) *pathFilter {
+	filter := &pathFilter{}
+
+	for _, glob := range include {
+		if negated, ok := strings.CutPrefix(glob, "!"); ok {
+			exclude = append(exclude, negated)
+			continue
+		}
+		filter.include = appendPathPattern(filter.include, glob)
+	}
+	for _, glob := range exclude {
+		filter.exclude = appendPathPattern(filter.exclude, glob)
+	}
+
+	if len(filter.include) == 0 {
+		filter.include = appendPathPattern(nil, defaultIncludePattern)
+	}
+
+	return filter
+}
+
+// appendPathPattern compiles a glob and appends it, skipping empty and invalid patterns
+func appendPathPattern(patterns []pathPattern, glob string) []pathPattern {
+	glob = strings.Trim(strings.TrimSpace(filepath.ToSlash(glob)), "/")
+	if glob == "" {
+		return patterns
+	}
+
+	expr, err := globToRegexp(glob)
+	if err == nil {
+		var regex *regexp.Regexp
+		if regex, err = regexp.Compile("^" + expr + "$"); err == nil {
+			return append(patterns, pathPattern{glob: glob, root: globRoot(glob), regex: regex})
+		}
+	}
+
+	log.Printf("Warning: ignoring invalid path pattern %q: %v", glob, err)
+	return patterns
+}
+
+// globRoot returns the directory part of a glob before its first wildcard
+func globRoot(glob string) string {
+	segments := strings.Split(glob, "/")
+
+	var root []string
+	for _, segment := range segments[:len(segments)-1] {
+		if strings.ContainsAny(segment, `*?[\`) {
+			break
+		}
+		root = append(root, segment)
+	}
+
+	return strings.Join(root, "/")
+}
+
+// withIncludes returns a filter with the same excludes and different include patterns
+func (f *pathFilter) withIncludes(include []string) *pathFilter {
+	filtered := newPathFilter(include, nil)
+	filtered.exclude = append(filtered.exclude, f.exclude...)
+	return filtered
+}
+
+// matches reports whether a vault-relative note path is included and not excluded.
+// An exclude pattern matching one of the parent directories excludes the note as well.
+func (f *pathFilter) matches(relPath string) bool {
+	if _, ok := f.includeRoot(relPath); !ok {
+		return false
+	}
+
+	for _, pattern := range f.exclude {
+		for dir := relPath; dir != "."; dir = path.Dir(dir) {
+			if pattern.regex.MatchString(dir) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// includeRoot returns the root directory of the first include pattern matching the path
+func (f *pathFilter) includeRoot(relPath string) (string, bool) {
+	for _, pattern := range f.include {
+		if pattern.regex.MatchString(relPath) {
+			return pattern.root, true
+		}
+	}
+	return "", false
+}
+
+// roots returns the minimal set of directories that need to be walked to find all included notes
+func (f *pathFilter) roots() []string {
+	var roots []string
+	for _, pattern := range f.include {
+		roots = append(roots, pattern.root)
+	}
+	sort.Strings(roots)
+
+	// Drop duplicates and directories below another root
+	var minimal []string
+	for _, root := range roots {
+		covered := false
+		for _, parent := range minimal {
+			if parent == "" || root == parent || strings.HasPrefix(root, parent+"/") {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			minimal = append(minimal, root)
+		}
+	}
+
+	return minimal
+}
+
+// isIncluded reports whether a path is selected by the configured include and exclude patterns
+func (idx *ObsidianIndexer) isIncluded(path string) bool {
+	if idx.filter == nil {
+		return true
+	}
+
+	relPath, err := vaultRelativePath(idx.vaultPath, path)
+	if err != nil {
+		return false
+	}
+
+	return idx.filter.matches(relPath)
+}
+
+// WatchRoots returns the vault directories that contain the notes selected for indexing
+func (idx *ObsidianIndexer) WatchRoots() []string {
+	filter := idx.filter
+	if filter == nil {
+		filter = newPathFilter(nil, nil)
+	}
+
+	var roots []string
+	for _, root := range filter.roots() {
+		roots = append(roots, filepath.Join(idx.vaultPath, filepath.FromSlash(root)))
+	}
+	return roots
+}
@@ -0,0 +1,103 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPathFilter tests include and exclude globs relative to the vault root
+func TestPathFilter(t *testing.T) {
+	filter := newPathFilter([]string{"**/*.md", "!Archive/**"}, []string{"**/drafts", "Daily/2023-*.md"})
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"README.md", true},
+		{"Projects/Alpha/plan.md", true},
+		{"Projects/Alpha/diagram.png", false},
+		{"Archive/old.md", false},
+		{"Archive/2023/older.md", false},
+		{"Notes/drafts/idea.md", false}, // Excluded parent folder
+		{"Notes/drafts.md", true},
+		{"Daily/2023-01-01.md", false},
+		{"Daily/2024-01-01.md", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, filter.matches(tt.path))
+		})
+	}
+}
+
+// TestPathFilterRoots tests that only the directories of the include patterns are walked
+func TestPathFilterRoots(t *testing.T) {
+	assert.Equal(t, []string{"Projects", "Zettelkasten"},
+		newPathFilter(directoryPatterns([]string{"Zettelkasten", "Projects", "Projects/Alpha"}), nil).roots())
+	assert.Equal(t, []string{""}, newPathFilter([]string{"Daily/*.md", "**/*.md"}, nil).roots())
+	assert.Equal(t, []string{""}, newPathFilter(nil, nil).roots())
+	assert.Equal(t, []string{""}, newPathFilter(directoryPatterns([]string{"."}), nil).roots())
+}
+
+// TestExtractFolderCategoriesWholeVault tests that any folder produces categories when the whole vault is included
+func TestExtractFolderCategoriesWholeVault(t *testing.T) {
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath: "/home/user/vault",
+		Include:   []string{"Projects/**/*.md", "**/*.md"},
+	})
+
+	assert.Equal(t, []string{"Areas", "Health"}, indexer.extractFolderCategories("/home/user/vault/Areas/Health/sleep.md"))
+	assert.Equal(t, []string{"ClientA"}, indexer.extractFolderCategories("/home/user/vault/Projects/ClientA/plan.md"))
+	assert.Equal(t, []string{}, indexer.extractFolderCategories("/home/user/vault/README.md"))
+}
+
+func TestIncludeAndExcludePatterns(t *testing.T) {
+	content := "# Note\n\nContent that is long enough to be indexed."
+	vaultDir, _, _ := newIgnoreTestVault(t, map[string]string{
+		"Inbox.md":               content,
+		"Areas/Health/sleep.md":  content,
+		"Archive/2020/old.md":    content,
+		"Projects/Alpha/plan.md": content,
+	})
+
+	mockClient := NewMockChromaClient()
+	config := &Config{
+		VaultPath:    vaultDir,
+		BatchSize:    10,
+		Include:      []string{"**/*.md"},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	result, err := indexer.ReindexVault(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.IndexedFiles)
+
+	// Excluding a folder later purges the notes in it
+	config.Exclude = []string{"Archive/**"}
+	indexer = NewObsidianIndexer(mockClient, config)
+	archived := filepath.Join(vaultDir, "Archive", "2020", "old.md")
+	archivedIDs := indexer.fileIndex[archived].ChunkIDs
+	require.NotEmpty(t, archivedIDs)
+
+	result, err = indexer.ReindexVault(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.SkippedFiles)
+	assert.Equal(t, 1, result.DeletedFiles)
+	assert.Equal(t, archivedIDs, mockClient.GetDeletedIDs())
+	assert.NotContains(t, indexer.fileIndex, archived)
+
+	// Restricting a run to a directory leaves notes elsewhere alone
+	result, err = indexer.ReindexVault(ctx, []string{"Projects"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.ProcessedFiles)
+	assert.Equal(t, 0, result.DeletedFiles)
+	assert.Len(t, indexer.fileIndex, 3)
+}
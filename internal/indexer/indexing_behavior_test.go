@@ -5,17 +5,29 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
 	"obsidian-ai-agent/internal/chroma"
+	"obsidian-ai-agent/internal/markdown"
 )
 
 // MockChromaClient implements the ChromaClient interface for testing
 type MockChromaClient struct {
-	UpsertCalls  [][]chroma.Document
-	UpsertErrors []error
-	callIndex    int
+	mu              sync.Mutex
+	UpsertCalls     [][]chroma.Document
+	UpsertErrors    []error
+	DeleteCalls     [][]string
+	DeletePathCalls []string
+	DeletePathKeeps [][]string
+	DeleteErrors    []error
+	MoveCalls       [][]chroma.DocumentMove
+	MoveErrors      []error
+	callIndex       int
+	deleteIndex     int
+	moveIndex       int
 }
 
 func NewMockChromaClient() *MockChromaClient {
@@ -26,6 +38,9 @@ func NewMockChromaClient() *MockChromaClient {
 }
 
 func (m *MockChromaClient) UpsertDocuments(ctx context.Context, documents []chroma.Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.UpsertCalls = append(m.UpsertCalls, documents)
 
 	if m.callIndex < len(m.UpsertErrors) {
@@ -37,8 +52,65 @@ func (m *MockChromaClient) UpsertDocuments(ctx context.Context, documents []chro
 	return nil
 }
 
+func (m *MockChromaClient) DeleteDocuments(ctx context.Context, ids []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeleteCalls = append(m.DeleteCalls, ids)
+	return m.nextDeleteError()
+}
+
+func (m *MockChromaClient) DeleteDocumentsByPath(ctx context.Context, path string, keepIDs []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.DeletePathCalls = append(m.DeletePathCalls, path)
+	m.DeletePathKeeps = append(m.DeletePathKeeps, keepIDs)
+	return m.nextDeleteError()
+}
+
+func (m *MockChromaClient) MoveDocuments(ctx context.Context, moves []chroma.DocumentMove) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.MoveCalls = append(m.MoveCalls, moves)
+
+	if m.moveIndex < len(m.MoveErrors) {
+		err := m.MoveErrors[m.moveIndex]
+		m.moveIndex++
+		return err
+	}
+	m.moveIndex++
+	return nil
+}
+
+func (m *MockChromaClient) nextDeleteError() error {
+	if m.deleteIndex < len(m.DeleteErrors) {
+		err := m.DeleteErrors[m.deleteIndex]
+		m.deleteIndex++
+		return err
+	}
+	m.deleteIndex++
+	return nil
+}
+
+// GetDeletedIDs returns all document IDs passed to DeleteDocuments
+func (m *MockChromaClient) GetDeletedIDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []string
+	for _, call := range m.DeleteCalls {
+		ids = append(ids, call...)
+	}
+	return ids
+}
+
 // GetTotalUpsertedDocuments returns the total number of documents upserted across all calls
 func (m *MockChromaClient) GetTotalUpsertedDocuments() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	total := 0
 	for _, docs := range m.UpsertCalls {
 		total += len(docs)
@@ -48,6 +120,9 @@ func (m *MockChromaClient) GetTotalUpsertedDocuments() int {
 
 // GetUpsertCallCount returns the number of times UpsertDocuments was called
 func (m *MockChromaClient) GetUpsertCallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	return len(m.UpsertCalls)
 }
 
@@ -325,6 +400,50 @@ Some static content.`
 	}
 }
 
+// TestOlderNormalizerVersionReindexing tests that notes rendered by an older markdown normaliser
+// are indexed again
+func TestOlderNormalizerVersionReindexing(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "note.md")
+
+	if err := os.WriteFile(testFile, []byte("# Note\n\nStatus:: active"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath:   tempDir,
+		BatchSize:   10,
+		Directories: []string{"."},
+		ChunkSize:   200,
+	})
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	if version := indexer.fileIndex[testFile].NormalizerVersion; version != markdown.Version {
+		t.Fatalf("Expected normalizer version %d to be recorded, got %d", markdown.Version, version)
+	}
+
+	entry := indexer.fileIndex[testFile]
+	entry.NormalizerVersion = 0 // Indexed before the version was recorded
+	indexer.fileIndex[testFile] = entry
+	if err := indexer.saveFileIndex(); err != nil {
+		t.Fatalf("Failed to save file index: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+	if result.UpdatedFiles != 1 || result.SkippedFiles != 0 {
+		t.Errorf("Expected the note to be indexed again, got %d updated and %d skipped", result.UpdatedFiles, result.SkippedFiles)
+	}
+	if version := indexer.fileIndex[testFile].NormalizerVersion; version != markdown.Version {
+		t.Errorf("Expected normalizer version %d after re-indexing, got %d", markdown.Version, version)
+	}
+}
+
 // TestBatchProcessing tests that large numbers of files are processed in batches
 func TestBatchProcessing(t *testing.T) {
 	// Create temporary directory with multiple files
@@ -435,3 +554,717 @@ This file will cause a ChromaDB error during indexing.`
 		t.Errorf("Expected 1 upsert call despite error, got %d", mockClient.GetUpsertCallCount())
 	}
 }
+
+// TestDeletedFilePurging tests that files removed from the vault have their chunks deleted
+func TestDeletedFilePurging(t *testing.T) {
+	tempDir := t.TempDir()
+	keptFile := filepath.Join(tempDir, "kept_note.md")
+	deletedFile := filepath.Join(tempDir, "deleted_note.md")
+
+	for _, file := range []string{keptFile, deletedFile} {
+		content := fmt.Sprintf("# %s\n\nContent that is long enough to be indexed.", filepath.Base(file))
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	mockClient := NewMockChromaClient()
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	deletedChunkIDs := indexer.fileIndex[deletedFile].ChunkIDs
+
+	if err := os.Remove(deletedFile); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+
+	if result.DeletedFiles != 1 {
+		t.Errorf("Expected 1 deleted file, got %d", result.DeletedFiles)
+	}
+	if result.SkippedFiles != 1 {
+		t.Errorf("Expected 1 skipped file, got %d", result.SkippedFiles)
+	}
+	if len(mockClient.DeleteCalls) != 1 || fmt.Sprint(mockClient.DeleteCalls[0]) != fmt.Sprint(deletedChunkIDs) {
+		t.Errorf("Expected delete call for chunks %v, got %v", deletedChunkIDs, mockClient.DeleteCalls)
+	}
+	if _, exists := indexer.fileIndex[deletedFile]; exists {
+		t.Errorf("Expected %s to be removed from the file index", deletedFile)
+	}
+
+	// A third run should not try to delete the file again
+	result, err = indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Third ReindexVault failed: %v", err)
+	}
+	if result.DeletedFiles != 0 {
+		t.Errorf("Expected 0 deleted files on third run, got %d", result.DeletedFiles)
+	}
+}
+
+// TestDeletedFilePurgingRetriesOnError tests that failed deletions keep the index entry for a retry
+func TestDeletedFilePurgingRetriesOnError(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "note.md")
+
+	if err := os.WriteFile(testFile, []byte("# Note\n\nContent that is long enough to be indexed."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	mockClient.DeleteErrors = []error{fmt.Errorf("simulated ChromaDB error")}
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	if err := os.Remove(testFile); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+	if result.DeletedFiles != 0 || len(result.Errors) == 0 {
+		t.Errorf("Expected failed deletion to be reported, got deleted=%d errors=%v", result.DeletedFiles, result.Errors)
+	}
+
+	result, err = indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Third ReindexVault failed: %v", err)
+	}
+	if result.DeletedFiles != 1 {
+		t.Errorf("Expected deletion to be retried and succeed, got %d deleted", result.DeletedFiles)
+	}
+}
+
+// TestShrunkFileDeletesOrphanedChunks tests that chunks beyond the new chunk count are deleted
+func TestShrunkFileDeletesOrphanedChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "shrinking_note.md")
+
+	longContent := `# Shrinking Note
+
+Introduction paragraph with enough words.
+
+## Section One
+
+First section content that is reasonably long.
+
+## Section Two
+
+Second section content that is reasonably long.
+
+## Section Three
+
+Third section content that is reasonably long.`
+
+	if err := os.WriteFile(testFile, []byte(longContent), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    50,
+		ChunkOverlap: 10,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	originalIDs := indexer.fileIndex[testFile].ChunkIDs
+	if len(originalIDs) < 2 {
+		t.Fatalf("Expected multiple chunk IDs to be tracked, got %d", len(originalIDs))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(testFile, []byte("# Shrinking Note\n\nOnly a short intro remains."), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+
+	newIDs := indexer.fileIndex[testFile].ChunkIDs
+	expectedStale := staleChunkIDs(originalIDs, newIDs)
+	if len(expectedStale) == 0 {
+		t.Fatalf("Expected the shrunk file to leave stale chunks behind")
+	}
+
+	deleted := mockClient.GetDeletedIDs()
+	if fmt.Sprint(deleted) != fmt.Sprint(expectedStale) {
+		t.Errorf("Expected stale chunks %v to be deleted, got %v", expectedStale, deleted)
+	}
+	if result.DeletedChunks != len(expectedStale) {
+		t.Errorf("Expected %d deleted chunks, got %d", len(expectedStale), result.DeletedChunks)
+	}
+	for _, id := range newIDs {
+		for _, deletedID := range deleted {
+			if id == deletedID {
+				t.Errorf("Current chunk %s should not have been deleted", id)
+			}
+		}
+	}
+}
+
+// TestLegacyEntryIsClearedAfterUpsert tests that the previous chunks of notes indexed before chunk
+// IDs were tracked are deleted by path only once their new chunks are upserted, keeping those
+func TestLegacyEntryIsClearedAfterUpsert(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "legacy_note.md")
+
+	if err := os.WriteFile(testFile, []byte("# Legacy\n\nIndexed before chunk IDs were tracked."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	legacy := indexer.fileIndex[testFile]
+	legacy.ChunkIDs = nil
+	legacy.ContentHash = "legacy"
+	indexer.fileIndex[testFile] = legacy
+	if err := indexer.saveFileIndex(); err != nil {
+		t.Fatalf("Failed to save file index: %v", err)
+	}
+
+	mockClient.UpsertErrors = []error{nil, fmt.Errorf("simulated ChromaDB error")}
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+	if len(mockClient.DeletePathCalls) != 0 {
+		t.Errorf("Expected no deletion by path before the upsert succeeds, got %v", mockClient.DeletePathCalls)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Third ReindexVault failed: %v", err)
+	}
+	if result.UpdatedFiles != 1 {
+		t.Errorf("Expected 1 updated file, got %d", result.UpdatedFiles)
+	}
+	if fmt.Sprint(mockClient.DeletePathCalls) != fmt.Sprint([]string{testFile}) {
+		t.Fatalf("Expected the previous chunks of %s to be deleted by path, got %v", testFile, mockClient.DeletePathCalls)
+	}
+	if kept := indexer.fileIndex[testFile].ChunkIDs; fmt.Sprint(mockClient.DeletePathKeeps[0]) != fmt.Sprint(kept) {
+		t.Errorf("Expected the new chunks %v to be kept, got %v", kept, mockClient.DeletePathKeeps[0])
+	}
+}
+
+// TestModifiedFileEmbedsOnlyChangedChunks tests that editing a note only embeds its new and changed
+// chunks, updates the metadata of chunks that moved and deletes the chunks that were removed
+func TestModifiedFileEmbedsOnlyChangedChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "sections.md")
+
+	original := "# Alpha\n\nAlpha text about apples.\n\n# Beta\n\nBeta text about bananas.\n\n# Gamma\n\nGamma text about grapes."
+	if err := os.WriteFile(testFile, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	indexer := NewObsidianIndexer(mockClient, &Config{
+		VaultPath:   tempDir,
+		BatchSize:   10,
+		Directories: []string{"."},
+		ChunkSize:   2000,
+	})
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	originalIDs := indexer.fileIndex[testFile].ChunkIDs
+	if len(originalIDs) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(originalIDs))
+	}
+
+	// Insert a section before the others and edit the last one
+	modified := "# Intro\n\nA new introduction.\n\n# Alpha\n\nAlpha text about apples.\n\n# Beta\n\nBeta text about bananas.\n\n# Gamma\n\nGamma text about grapes and figs."
+	if err := os.WriteFile(testFile, []byte(modified), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+	if result.UpdatedFiles != 1 || result.EmbeddedChunks != 2 || result.UnchangedChunks != 2 {
+		t.Errorf("Expected 1 updated file with 2 embedded and 2 unchanged chunks, got %d, %d and %d", result.UpdatedFiles, result.EmbeddedChunks, result.UnchangedChunks)
+	}
+
+	upserted := mockClient.UpsertCalls[len(mockClient.UpsertCalls)-1]
+	if len(upserted) != 2 || upserted[0].Content != "# Intro A new introduction." || upserted[1].Content != "# Gamma Gamma text about grapes and figs." {
+		t.Errorf("Expected only the new and edited sections to be embedded, got %v", upserted)
+	}
+
+	if len(mockClient.MoveCalls) != 1 || len(mockClient.MoveCalls[0]) != 2 {
+		t.Fatalf("Expected 1 metadata update of 2 unchanged chunks, got %v", mockClient.MoveCalls)
+	}
+	for i, move := range mockClient.MoveCalls[0] {
+		if move.OldID != originalIDs[i] || move.Document.ID != originalIDs[i] {
+			t.Errorf("Unchanged chunk %d: expected to keep ID %s, got %s -> %s", i, originalIDs[i], move.OldID, move.Document.ID)
+		}
+		if move.Document.Metadata["chunk_index"] != i+1 {
+			t.Errorf("Unchanged chunk %d: expected chunk_index %d, got %v", i, i+1, move.Document.Metadata["chunk_index"])
+		}
+	}
+
+	if deleted := mockClient.GetDeletedIDs(); fmt.Sprint(deleted) != fmt.Sprint(originalIDs[2:]) {
+		t.Errorf("Expected only the old version of the edited section to be deleted, got %v", deleted)
+	}
+}
+
+// TestUnchangedChunksFallBackToEmbedding tests that unchanged chunks are embedded again when their
+// stored embeddings cannot be reused
+func TestUnchangedChunksFallBackToEmbedding(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "sections.md")
+
+	if err := os.WriteFile(testFile, []byte("# Alpha\n\nAlpha text about apples.\n\n# Beta\n\nBeta text about bananas."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	mockClient.MoveErrors = []error{fmt.Errorf("no stored embedding found")}
+	indexer := NewObsidianIndexer(mockClient, &Config{
+		VaultPath:   tempDir,
+		BatchSize:   10,
+		Directories: []string{"."},
+		ChunkSize:   2000,
+	})
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+
+	if err := os.WriteFile(testFile, []byte("# Alpha\n\nAlpha text about apples.\n\n# Beta\n\nBeta text about blueberries."), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+	if len(result.Errors) != 0 || result.UpdatedFiles != 1 {
+		t.Errorf("Expected the file to be updated without errors, got %d updated and errors %v", result.UpdatedFiles, result.Errors)
+	}
+	if result.EmbeddedChunks != 2 || result.UnchangedChunks != 0 {
+		t.Errorf("Expected both chunks to be embedded, got %d embedded and %d unchanged", result.EmbeddedChunks, result.UnchangedChunks)
+	}
+
+	lastUpsert := mockClient.UpsertCalls[len(mockClient.UpsertCalls)-1]
+	if len(lastUpsert) != 1 || lastUpsert[0].Content != "# Alpha Alpha text about apples." {
+		t.Errorf("Expected the unchanged chunk to be upserted again, got %v", lastUpsert)
+	}
+}
+
+// TestRenamedFileMovesChunks tests that a renamed file reuses its chunks instead of being re-embedded
+func TestRenamedFileMovesChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	oldFile := filepath.Join(tempDir, "old_name.md")
+	newFile := filepath.Join(tempDir, "new_name.md")
+
+	content := `# Renamed Note
+
+This note will be renamed without any content changes.
+
+## Section
+
+More content in a second section.`
+
+	if err := os.WriteFile(oldFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    50,
+		ChunkOverlap: 10,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	oldIDs := indexer.fileIndex[oldFile].ChunkIDs
+	initialUpsertCount := mockClient.GetUpsertCallCount()
+
+	if err := os.Rename(oldFile, newFile); err != nil {
+		t.Fatalf("Failed to rename test file: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+
+	if result.RenamedFiles != 1 {
+		t.Errorf("Expected 1 renamed file, got %d", result.RenamedFiles)
+	}
+	if result.IndexedFiles != 0 || result.DeletedFiles != 0 {
+		t.Errorf("Expected no new or deleted files, got new=%d deleted=%d", result.IndexedFiles, result.DeletedFiles)
+	}
+	if mockClient.GetUpsertCallCount() != initialUpsertCount {
+		t.Errorf("Expected no re-embedding upserts for a rename, got %d new calls", mockClient.GetUpsertCallCount()-initialUpsertCount)
+	}
+
+	if len(mockClient.MoveCalls) != 1 {
+		t.Fatalf("Expected 1 move call, got %d", len(mockClient.MoveCalls))
+	}
+	moves := mockClient.MoveCalls[0]
+	if len(moves) != len(oldIDs) {
+		t.Fatalf("Expected %d moved chunks, got %d", len(oldIDs), len(moves))
+	}
+	for i, move := range moves {
+		if move.OldID != oldIDs[i] {
+			t.Errorf("Move %d: expected old ID %s, got %s", i, oldIDs[i], move.OldID)
+		}
+		if move.Document.Metadata["path"] != newFile {
+			t.Errorf("Move %d: expected path %s, got %v", i, newFile, move.Document.Metadata["path"])
+		}
+		if move.Document.Metadata["filename"] != "new_name.md" {
+			t.Errorf("Move %d: expected filename new_name.md, got %v", i, move.Document.Metadata["filename"])
+		}
+	}
+
+	if _, exists := indexer.fileIndex[oldFile]; exists {
+		t.Errorf("Expected old path to be removed from the file index")
+	}
+	if _, exists := indexer.fileIndex[newFile]; !exists {
+		t.Errorf("Expected new path to be tracked in the file index")
+	}
+}
+
+// TestRenamedFileFallsBackOnMoveError tests that a failed move re-indexes the file and purges the old path
+func TestRenamedFileFallsBackOnMoveError(t *testing.T) {
+	tempDir := t.TempDir()
+	oldFile := filepath.Join(tempDir, "old_name.md")
+	newFile := filepath.Join(tempDir, "new_name.md")
+
+	if err := os.WriteFile(oldFile, []byte("# Note\n\nContent that is long enough to be indexed."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	mockClient.MoveErrors = []error{fmt.Errorf("simulated ChromaDB error")}
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	if err := os.Rename(oldFile, newFile); err != nil {
+		t.Fatalf("Failed to rename test file: %v", err)
+	}
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+
+	if result.RenamedFiles != 0 || result.IndexedFiles != 1 || result.DeletedFiles != 1 {
+		t.Errorf("Expected fallback to index+delete, got renamed=%d new=%d deleted=%d",
+			result.RenamedFiles, result.IndexedFiles, result.DeletedFiles)
+	}
+}
+
+// TestIndexFilesOnlyTouchesGivenPaths tests targeted indexing of watcher-reported paths
+func TestIndexFilesOnlyTouchesGivenPaths(t *testing.T) {
+	tempDir := t.TempDir()
+	subDir := filepath.Join(tempDir, "archive")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+
+	changedFile := filepath.Join(tempDir, "changed.md")
+	untouchedFile := filepath.Join(tempDir, "untouched.md")
+	archivedFile := filepath.Join(subDir, "archived.md")
+	for _, file := range []string{changedFile, untouchedFile, archivedFile} {
+		if err := os.WriteFile(file, []byte("# Note\n\nContent that is long enough to be indexed."), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	mockClient := NewMockChromaClient()
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.ReindexVault(ctx, []string{"."}); err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err := os.WriteFile(changedFile, []byte("# Note\n\nChanged content that is long enough."), 0644); err != nil {
+		t.Fatalf("Failed to modify test file: %v", err)
+	}
+	if err := os.RemoveAll(subDir); err != nil {
+		t.Fatalf("Failed to remove subdirectory: %v", err)
+	}
+
+	result, err := indexer.IndexFiles(ctx, []string{changedFile, subDir})
+	if err != nil {
+		t.Fatalf("IndexFiles failed: %v", err)
+	}
+
+	if result.ProcessedFiles != 1 || result.UpdatedFiles != 1 {
+		t.Errorf("Expected only the changed file to be processed, got processed=%d updated=%d",
+			result.ProcessedFiles, result.UpdatedFiles)
+	}
+	if result.DeletedFiles != 1 {
+		t.Errorf("Expected the file in the removed directory to be deleted, got %d", result.DeletedFiles)
+	}
+	if _, exists := indexer.fileIndex[archivedFile]; exists {
+		t.Errorf("Expected %s to be removed from the file index", archivedFile)
+	}
+	if _, exists := indexer.fileIndex[untouchedFile]; !exists {
+		t.Errorf("Expected %s to remain in the file index", untouchedFile)
+	}
+}
+
+// TestParallelIndexingIsDeterministic tests that concurrent readers and upserters produce the same
+// accounting and documents as a sequential run
+func TestParallelIndexingIsDeterministic(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for i := 0; i < 25; i++ {
+		fileName := filepath.Join(tempDir, fmt.Sprintf("note_%02d.md", i))
+		content := fmt.Sprintf("# Note %d\n\nContent for note %d.\n\n## Details\n\nMore details about note %d.", i, i, i)
+		if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file %d: %v", i, err)
+		}
+	}
+
+	run := func(workers, upsertWorkers int) (*IndexResult, []string) {
+		mockClient := NewMockChromaClient()
+		config := &Config{
+			VaultPath:     tempDir,
+			BatchSize:     4,
+			Directories:   []string{"."},
+			ChunkSize:     200,
+			ChunkOverlap:  50,
+			Workers:       workers,
+			UpsertWorkers: upsertWorkers,
+			// Keep the index file out of the shared vault so both runs start fresh
+			StateStore: NewJSONStateStore(filepath.Join(t.TempDir(), IndexFileName), tempDir),
+		}
+
+		indexer := NewObsidianIndexer(mockClient, config)
+
+		result, err := indexer.ReindexVault(context.Background(), []string{"."})
+		if err != nil {
+			t.Fatalf("ReindexVault failed: %v", err)
+		}
+
+		var ids []string
+		for _, docs := range mockClient.UpsertCalls {
+			for _, doc := range docs {
+				ids = append(ids, doc.ID)
+			}
+		}
+		sort.Strings(ids)
+		return result, ids
+	}
+
+	sequential, sequentialIDs := run(1, 1)
+	parallel, parallelIDs := run(8, 3)
+
+	if parallel.ProcessedFiles != sequential.ProcessedFiles ||
+		parallel.IndexedFiles != sequential.IndexedFiles ||
+		parallel.BatchesUploaded != sequential.BatchesUploaded ||
+		len(parallel.Errors) != len(sequential.Errors) {
+		t.Errorf("Parallel result %+v differs from sequential result %+v", parallel, sequential)
+	}
+	if fmt.Sprint(parallelIDs) != fmt.Sprint(sequentialIDs) {
+		t.Errorf("Parallel run upserted different documents than sequential run")
+	}
+	if parallel.IndexedFiles != 25 {
+		t.Errorf("Expected 25 indexed files, got %d", parallel.IndexedFiles)
+	}
+}
+
+// TestFailedUpsertIsRetried tests that files of a failed batch are not marked as indexed
+func TestFailedUpsertIsRetried(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "retry_note.md")
+
+	if err := os.WriteFile(testFile, []byte("# Retry\n\nThis file fails to upload the first time."), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	mockClient := NewMockChromaClient()
+	mockClient.UpsertErrors = []error{fmt.Errorf("simulated ChromaDB error")}
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Initial ReindexVault failed: %v", err)
+	}
+	if result.FailedFiles != 1 || result.IndexedFiles != 0 {
+		t.Errorf("Expected 1 failed and 0 indexed files, got failed=%d indexed=%d", result.FailedFiles, result.IndexedFiles)
+	}
+	if _, exists := indexer.fileIndex[testFile]; exists {
+		t.Errorf("Expected failed file not to be recorded in the file index")
+	}
+
+	result, err = indexer.ReindexVault(ctx, []string{"."})
+	if err != nil {
+		t.Fatalf("Second ReindexVault failed: %v", err)
+	}
+	if result.IndexedFiles != 1 || result.SkippedFiles != 0 {
+		t.Errorf("Expected the failed file to be retried, got indexed=%d skipped=%d", result.IndexedFiles, result.SkippedFiles)
+	}
+	if mockClient.GetUpsertCallCount() != 2 {
+		t.Errorf("Expected 2 upsert calls, got %d", mockClient.GetUpsertCallCount())
+	}
+}
+
+// TestFailedUpsertIsRetriedByIndexFiles tests that watch-mode runs pick up previously failed files
+func TestFailedUpsertIsRetriedByIndexFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	failedFile := filepath.Join(tempDir, "failed.md")
+	otherFile := filepath.Join(tempDir, "other.md")
+
+	for _, file := range []string{failedFile, otherFile} {
+		if err := os.WriteFile(file, []byte("# Note\n\nContent that is long enough to be indexed."), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	mockClient := NewMockChromaClient()
+	mockClient.UpsertErrors = []error{fmt.Errorf("simulated ChromaDB error")}
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    10,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx := context.Background()
+
+	if _, err := indexer.IndexFiles(ctx, []string{failedFile}); err != nil {
+		t.Fatalf("IndexFiles failed: %v", err)
+	}
+
+	result, err := indexer.IndexFiles(ctx, []string{otherFile})
+	if err != nil {
+		t.Fatalf("IndexFiles failed: %v", err)
+	}
+	if result.IndexedFiles != 2 {
+		t.Errorf("Expected the failed file to be retried alongside the new one, got %d indexed", result.IndexedFiles)
+	}
+}
+
+// TestCancelledContextStopsIndexing tests that a cancelled context stops indexing without committing files
+func TestCancelledContextStopsIndexing(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for i := 0; i < 5; i++ {
+		fileName := filepath.Join(tempDir, fmt.Sprintf("note_%d.md", i))
+		if err := os.WriteFile(fileName, []byte(fmt.Sprintf("# Note %d\n\nContent for note %d.", i, i)), 0644); err != nil {
+			t.Fatalf("Failed to create test file %d: %v", i, err)
+		}
+	}
+
+	mockClient := NewMockChromaClient()
+	config := &Config{
+		VaultPath:    tempDir,
+		BatchSize:    2,
+		Directories:  []string{"."},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	}
+
+	indexer := NewObsidianIndexer(mockClient, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := indexer.ReindexVault(ctx, []string{"."})
+	if err == nil {
+		t.Fatalf("Expected ReindexVault to report cancellation")
+	}
+	if mockClient.GetUpsertCallCount() != 0 {
+		t.Errorf("Expected no upserts after cancellation, got %d", mockClient.GetUpsertCallCount())
+	}
+	if result.IndexedFiles != 0 || len(indexer.fileIndex) != 0 {
+		t.Errorf("Expected no files to be committed, got indexed=%d index=%d", result.IndexedFiles, len(indexer.fileIndex))
+	}
+}
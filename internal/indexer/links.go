@@ -0,0 +1,286 @@
+package indexer
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Link is a wikilink or embed found in a note
+type Link struct {
+	Target   string `json:"target"`             // Linked note as written, e.g. "Folder/Note"; empty for links within the note
+	Heading  string `json:"heading,omitempty"`  // Heading after "#", if any
+	Block    string `json:"block,omitempty"`    // Block ID after "#^", if any
+	Alias    string `json:"alias,omitempty"`    // Display text after "|", if any
+	Embed    bool   `json:"embed,omitempty"`    // The link is an embed ("![[...]]")
+	Resolved string `json:"resolved,omitempty"` // Vault-relative path of the linked note; empty if unresolved
+}
+
+// Backlink is a link to a note from another note
+type Backlink struct {
+	Source string `json:"source"` // Vault-relative path of the linking note
+	Link   Link   `json:"link"`
+}
+
+// NoteLinks describes the link structure around a single note
+type NoteLinks struct {
+	Path       string     `json:"path"`
+	Links      []Link     `json:"links"`
+	Unresolved []string   `json:"unresolved"`
+	Backlinks  []Backlink `json:"backlinks"`
+}
+
+// wikiLinkPattern matches [[links]] and ![[embeds]]
+var wikiLinkPattern = regexp.MustCompile(`(!?)\[\[([^\[\]\n]+?)\]\]`)
+
+// extractLinks returns the wikilinks and embeds of a note, outside code blocks and inline code.
+// Links to attachments (targets with an extension other than .md) are not part of the note graph.
+// The result is never nil, so entries that were indexed before links were tracked can be told apart.
+func extractLinks(content string) []Link {
+	links := []Link{}
+
+	for _, line := range proseLines(content) {
+		for _, match := range wikiLinkPattern.FindAllStringSubmatch(line, -1) {
+			link := parseWikiLink(match[2])
+			link.Embed = match[1] == "!"
+
+			if ext := path.Ext(link.Target); ext != "" && !strings.EqualFold(ext, ".md") {
+				continue // Attachment such as an image or PDF
+			}
+			links = append(links, link)
+		}
+	}
+
+	return links
+}
+
+// parseWikiLink splits the inside of a wikilink into target, heading or block, and alias
+func parseWikiLink(inner string) Link {
+	var link Link
+
+	// Aliases in tables are written with an escaped pipe
+	inner = strings.ReplaceAll(inner, `\|`, "|")
+	if target, alias, ok := strings.Cut(inner, "|"); ok {
+		inner = target
+		link.Alias = strings.TrimSpace(alias)
+	}
+
+	if target, anchor, ok := strings.Cut(inner, "#"); ok {
+		inner = target
+		anchor = strings.TrimSpace(anchor)
+		if block, isBlock := strings.CutPrefix(anchor, "^"); isBlock {
+			link.Block = block
+		} else {
+			link.Heading = anchor
+		}
+	}
+
+	link.Target = strings.TrimSpace(inner)
+	return link
+}
+
+// proseLines returns the lines of a note outside fenced code blocks, with inline code removed
+func proseLines(content string) []string {
+	var lines []string
+	inFence := ""
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = trimmed[:3]
+			continue
+		}
+
+		lines = append(lines, inlineCodeRegex.ReplaceAllString(line, " "))
+	}
+
+	return lines
+}
+
+// linkGraph is an immutable snapshot of the links between indexed notes, keyed by vault-relative path
+type linkGraph struct {
+	links     map[string][]Link
+	backlinks map[string][]Backlink
+}
+
+// buildLinkGraph resolves the links of all notes against each other
+func buildLinkGraph(notes map[string][]Link) *linkGraph {
+	graph := &linkGraph{
+		links:     make(map[string][]Link, len(notes)),
+		backlinks: make(map[string][]Backlink),
+	}
+
+	resolver := newNoteResolver(sortedNoteKeys(notes))
+
+	for _, source := range sortedNoteKeys(notes) {
+		resolved := make([]Link, len(notes[source]))
+		for i, link := range notes[source] {
+			link.Resolved = resolver.resolve(source, link.Target)
+			resolved[i] = link
+
+			if link.Resolved != "" && link.Resolved != source {
+				graph.backlinks[link.Resolved] = append(graph.backlinks[link.Resolved], Backlink{Source: source, Link: link})
+			}
+		}
+		graph.links[source] = resolved
+	}
+
+	return graph
+}
+
+// noteResolver resolves link targets to vault-relative note paths like Obsidian does:
+// by path first, then by note name, preferring the shortest path
+type noteResolver struct {
+	byPath map[string]string   // Lowercased path without extension -> note
+	byName map[string][]string // Lowercased name -> notes, shortest path first
+}
+
+// newNoteResolver indexes the given vault-relative note paths
+func newNoteResolver(notes []string) *noteResolver {
+	resolver := &noteResolver{
+		byPath: make(map[string]string, len(notes)),
+		byName: make(map[string][]string),
+	}
+
+	for _, note := range notes {
+		key := strings.ToLower(strings.TrimSuffix(note, path.Ext(note)))
+		resolver.byPath[key] = note
+		name := path.Base(key)
+		resolver.byName[name] = append(resolver.byName[name], note)
+	}
+	for name := range resolver.byName {
+		sort.Slice(resolver.byName[name], func(i, j int) bool {
+			a, b := resolver.byName[name][i], resolver.byName[name][j]
+			if len(a) != len(b) {
+				return len(a) < len(b)
+			}
+			return a < b
+		})
+	}
+
+	return resolver
+}
+
+// resolve returns the vault-relative path of the note a link target in source refers to,
+// or "" if no known note matches
+func (r *noteResolver) resolve(source, target string) string {
+	if target == "" {
+		return source // Link to a heading or block in the same note
+	}
+
+	key := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(target, "/"), ".md"))
+	if note, ok := r.byPath[key]; ok {
+		return note
+	}
+
+	// Relative to the linking note's folder
+	if note, ok := r.byPath[path.Join(strings.ToLower(path.Dir(source)), key)]; ok {
+		return note
+	}
+
+	// By name, when the link does not name a folder
+	if !strings.Contains(key, "/") {
+		if candidates := r.byName[key]; len(candidates) > 0 {
+			return candidates[0]
+		}
+	}
+
+	return ""
+}
+
+// sortedNoteKeys returns the keys of a note map in sorted order
+func sortedNoteKeys(notes map[string][]Link) []string {
+	keys := make([]string, 0, len(notes))
+	for key := range notes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rebuildLinkGraph replaces the link graph snapshot with the links recorded in the file index.
+// It must be called while no run is modifying the file index.
+func (idx *ObsidianIndexer) rebuildLinkGraph() {
+	notes := make(map[string][]Link, len(idx.fileIndex))
+	for file, entry := range idx.fileIndex {
+		relPath, err := vaultRelativePath(idx.vaultPath, file)
+		if err != nil {
+			continue
+		}
+		notes[relPath] = entry.Links
+	}
+
+	graph := buildLinkGraph(notes)
+
+	idx.graphMu.Lock()
+	idx.graph = graph
+	idx.graphMu.Unlock()
+}
+
+// NoteLinks returns the outgoing links, unresolved links and backlinks of a note, as of the end
+// of the last indexing run. The path may be vault-relative or a file path inside the vault.
+func (idx *ObsidianIndexer) NoteLinks(notePath string) (*NoteLinks, error) {
+	idx.graphMu.RLock()
+	graph := idx.graph
+	idx.graphMu.RUnlock()
+
+	if graph == nil {
+		return nil, fmt.Errorf("link graph is not available yet")
+	}
+
+	relPath, ok := graph.lookup(idx.vaultPath, notePath)
+	if !ok {
+		return nil, fmt.Errorf("note %s is not indexed", notePath)
+	}
+
+	result := &NoteLinks{
+		Path:       relPath,
+		Links:      graph.links[relPath],
+		Unresolved: []string{},
+		Backlinks:  graph.backlinks[relPath],
+	}
+	if result.Links == nil {
+		result.Links = []Link{}
+	}
+	if result.Backlinks == nil {
+		result.Backlinks = []Backlink{}
+	}
+
+	seen := make(map[string]bool)
+	for _, link := range result.Links {
+		if link.Resolved == "" && !seen[link.Target] {
+			seen[link.Target] = true
+			result.Unresolved = append(result.Unresolved, link.Target)
+		}
+	}
+
+	return result, nil
+}
+
+// lookup finds the graph key of a note given as vault-relative path (with or without ".md")
+// or as a file path inside the vault
+func (graph *linkGraph) lookup(vaultPath, notePath string) (string, bool) {
+	candidates := []string{
+		strings.TrimPrefix(notePath, "/"),
+		strings.TrimPrefix(notePath, "/") + ".md",
+	}
+	if relPath, err := vaultRelativePath(vaultPath, notePath); err == nil {
+		candidates = append(candidates, relPath)
+	}
+
+	for _, candidate := range candidates {
+		if _, ok := graph.links[candidate]; ok {
+			return candidate, true
+		}
+	}
+	return "", false
+}
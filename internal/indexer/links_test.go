@@ -0,0 +1,107 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseWikiLink tests splitting a wikilink into its parts
+func TestParseWikiLink(t *testing.T) {
+	tests := []struct {
+		inner    string
+		expected Link
+	}{
+		{"Note", Link{Target: "Note"}},
+		{"Folder/Note", Link{Target: "Folder/Note"}},
+		{"Note|Display text", Link{Target: "Note", Alias: "Display text"}},
+		{`Note\|Table alias`, Link{Target: "Note", Alias: "Table alias"}},
+		{"Note#Heading", Link{Target: "Note", Heading: "Heading"}},
+		{"Note#^block-1|see", Link{Target: "Note", Block: "block-1", Alias: "see"}},
+		{"#Local heading", Link{Heading: "Local heading"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.inner, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseWikiLink(tt.inner))
+		})
+	}
+}
+
+// TestExtractLinks tests link extraction from note content
+func TestExtractLinks(t *testing.T) {
+	content := "See [[Project Alpha]] and ![[Diagram]].\n" +
+		"Image: ![[photo.png]] and PDF [[paper.pdf]]\n" +
+		"Inline `[[Not a link]]` code\n" +
+		"```\n[[Inside fence]]\n```\n" +
+		"Back to [[notes/Beta.md#Goals|goals]]"
+
+	links := extractLinks(content)
+	assert.Equal(t, []Link{
+		{Target: "Project Alpha"},
+		{Target: "Diagram", Embed: true},
+		{Target: "notes/Beta.md", Heading: "Goals", Alias: "goals"},
+	}, links)
+
+	assert.NotNil(t, extractLinks("No links here"))
+}
+
+// TestBuildLinkGraph tests link resolution and backlinks
+func TestBuildLinkGraph(t *testing.T) {
+	graph := buildLinkGraph(map[string][]Link{
+		"index.md":             {{Target: "Alpha"}, {Target: "projects/beta"}, {Target: "Missing"}, {Heading: "Top"}},
+		"projects/alpha.md":    {{Target: "beta"}},
+		"projects/beta.md":     {},
+		"archive/old/alpha.md": {{Target: "index"}},
+	})
+
+	links := graph.links["index.md"]
+	require.Len(t, links, 4)
+	assert.Equal(t, "projects/alpha.md", links[0].Resolved, "shortest path wins for ambiguous names")
+	assert.Equal(t, "projects/beta.md", links[1].Resolved)
+	assert.Empty(t, links[2].Resolved)
+	assert.Equal(t, "index.md", links[3].Resolved, "links without target point at the note itself")
+
+	assert.Equal(t, "projects/beta.md", graph.links["projects/alpha.md"][0].Resolved)
+
+	assert.Equal(t, []Backlink{
+		{Source: "archive/old/alpha.md", Link: Link{Target: "index", Resolved: "index.md"}},
+	}, graph.backlinks["index.md"], "self links are not backlinks")
+	assert.Len(t, graph.backlinks["projects/beta.md"], 2)
+}
+
+// TestNoteLinksAfterIndexing tests the link graph built by an indexing run
+func TestNoteLinksAfterIndexing(t *testing.T) {
+	vaultDir, indexer, _ := newIgnoreTestVault(t, map[string]string{
+		"Home.md":           "# Home\n\nStart at [[Projects/Alpha|Alpha]] or [[Someday]].",
+		"Projects/Alpha.md": "# Alpha\n\nPart of [[Home]], see ![[Beta#Summary]].",
+		"Projects/Beta.md":  "# Beta\n\nShort.",
+	})
+
+	_, err := indexer.NoteLinks("Home.md")
+	assert.Error(t, err, "nothing is indexed before the first run")
+
+	_, err = indexer.ReindexVault(context.Background(), nil)
+	require.NoError(t, err)
+
+	home, err := indexer.NoteLinks("Home")
+	require.NoError(t, err)
+	assert.Equal(t, "Home.md", home.Path)
+	assert.Len(t, home.Links, 2)
+	assert.Equal(t, []string{"Someday"}, home.Unresolved)
+	require.Len(t, home.Backlinks, 1)
+	assert.Equal(t, "Projects/Alpha.md", home.Backlinks[0].Source)
+
+	beta, err := indexer.NoteLinks(filepath.Join(vaultDir, "Projects", "Beta.md"))
+	require.NoError(t, err)
+	assert.Empty(t, beta.Links, "short notes are still part of the graph")
+	require.Len(t, beta.Backlinks, 1)
+	assert.True(t, beta.Backlinks[0].Link.Embed)
+	assert.Equal(t, "Summary", beta.Backlinks[0].Link.Heading)
+
+	_, err = indexer.NoteLinks("Unknown.md")
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package indexer
+
+import (
+	"regexp"
+	"strings"
+
+	"obsidian-ai-agent/internal/markdown"
+)
+
+// calloutMetadataPrefix prefixes the boolean per-type callout keys, e.g. "callout:warning" = true
+const calloutMetadataPrefix = "callout:"
+
+var (
+	// calloutHeaderRegex matches the first line of a callout, e.g. "> [!warning]- Title"
+	calloutHeaderRegex = regexp.MustCompile(`^\s*>\s*\[!([\w-]+)\][+-]?\s*(.*)$`)
+	highlightRegex     = regexp.MustCompile(`==([^=\n]+?)==`)
+)
+
+// callout is an Obsidian callout block
+type callout struct {
+	Type   string // Lowercased callout type, e.g. "warning"
+	Title  string // Title after the type; empty if the callout uses its default title
+	header string // Header as rendered into the note text
+}
+
+// obsidianMarkup is the Obsidian-specific markup found in a note
+type obsidianMarkup struct {
+	callouts   []callout
+	highlights []string
+}
+
+// parseObsidianMarkup returns the callouts and highlighted passages of a note found outside
+// fenced code blocks. Their text is rendered by the markdown normaliser like the rest of the note.
+func parseObsidianMarkup(content string) obsidianMarkup {
+	var markup obsidianMarkup
+
+	inFence := ""
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = trimmed[:3]
+			continue
+		}
+
+		if match := calloutHeaderRegex.FindStringSubmatch(line); match != nil {
+			c := callout{Type: strings.ToLower(match[1]), Title: strings.TrimSpace(match[2])}
+			c.header = markdown.CalloutLabel(c.Type)
+			if c.Title != "" {
+				c.header += ": " + c.Title
+			}
+			markup.callouts = append(markup.callouts, c)
+			continue
+		}
+
+		for _, match := range highlightRegex.FindAllStringSubmatch(line, -1) {
+			if passage := strings.TrimSpace(match[1]); passage != "" {
+				markup.highlights = append(markup.highlights, passage)
+			}
+		}
+	}
+
+	return markup
+}
+
+// markupMetadata returns the chunk metadata for the callouts and highlights whose text appears
+// in the (cleaned) chunk content
+func (idx *ObsidianIndexer) markupMetadata(markup obsidianMarkup, content string) map[string]interface{} {
+	metadata := make(map[string]interface{})
+
+	var types, titles []string
+	seen := make(map[string]bool)
+	for _, c := range markup.callouts {
+		if !strings.Contains(content, idx.cleanContent(c.header)) {
+			continue
+		}
+		if !seen[c.Type] {
+			seen[c.Type] = true
+			types = append(types, c.Type)
+			metadata[calloutMetadataPrefix+c.Type] = true
+		}
+		if c.Title != "" {
+			titles = append(titles, idx.cleanContent(c.Title))
+		}
+	}
+	if len(types) > 0 {
+		metadata["callouts"] = strings.Join(types, ", ")
+	}
+	if len(titles) > 0 {
+		metadata["callout_titles"] = strings.Join(titles, " | ")
+	}
+
+	var highlights []string
+	for _, passage := range markup.highlights {
+		if passage = idx.cleanContent(passage); passage != "" && strings.Contains(content, passage) {
+			highlights = append(highlights, passage)
+		}
+	}
+	if len(highlights) > 0 {
+		metadata["highlights"] = strings.Join(highlights, " | ")
+		metadata["has_highlights"] = true
+	}
+
+	return metadata
+}
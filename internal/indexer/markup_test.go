@@ -0,0 +1,63 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseObsidianMarkup tests callout and highlight extraction
+func TestParseObsidianMarkup(t *testing.T) {
+	content := "Intro with ==key insight== here.\n\n" +
+		"> [!warning]- Check the backups\n> Restore tests run weekly.\n> ==Never skip them==\n\n" +
+		"> [!tip]\n> Default title\n\n" +
+		"> Plain quote\n\n" +
+		"```\n> [!note] In code\n==not highlighted==\n```"
+
+	markup := parseObsidianMarkup(content)
+
+	assert.Equal(t, []callout{
+		{Type: "warning", Title: "Check the backups", header: "Warning: Check the backups"},
+		{Type: "tip", header: "Tip"},
+	}, markup.callouts)
+	assert.Equal(t, []string{"key insight", "Never skip them"}, markup.highlights)
+}
+
+// TestMarkupChunkMetadata tests that comments are not embedded and callouts and highlights become metadata
+func TestMarkupChunkMetadata(t *testing.T) {
+	vaultDir := t.TempDir()
+	notePath := filepath.Join(vaultDir, "note.md")
+	content := "# Ops\n\nThe ==restore drill== matters. %%private scratch%%\n\n" +
+		"> [!danger] Data loss\n> Backups older than a week are deleted.\n\n" +
+		"%%\nTODO ask #finance about [[Budget]]\n%%\n\n## Later\n\nNothing special."
+	require.NoError(t, os.WriteFile(notePath, []byte(content), 0644))
+
+	indexer := &ObsidianIndexer{vaultPath: vaultDir, chunkSize: 1000, chunkOverlap: 100}
+	chunks, fileInfo, err := indexer.processFileWithChunks(notePath)
+	require.NoError(t, err)
+	require.Len(t, chunks, 2)
+
+	assert.Empty(t, fileInfo.Links, "links in comments are ignored")
+	for _, chunk := range chunks {
+		assert.NotContains(t, chunk.Content, "private")
+		assert.NotContains(t, chunk.Content, "TODO")
+		assert.NotContains(t, chunk.Metadata, "tag:finance")
+	}
+
+	first := chunks[0].Metadata
+	assert.Contains(t, chunks[0].Content, "Danger: Data loss Backups older than a week are deleted.")
+	assert.Equal(t, "danger", first["callouts"])
+	assert.Equal(t, "Data loss", first["callout_titles"])
+	assert.Equal(t, true, first["callout:danger"])
+	assert.Equal(t, "restore drill", first["highlights"])
+	assert.Equal(t, true, first["has_highlights"])
+
+	// Chunks without callouts or highlights get no markup metadata
+	second := chunks[1].Metadata
+	assert.NotContains(t, second, "callouts")
+	assert.NotContains(t, second, "highlights")
+	assert.NotContains(t, second, "has_highlights")
+}
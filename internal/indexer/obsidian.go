@@ -4,25 +4,34 @@ import (
 	"context"
 	"crypto/md5"
 	"crypto/sha256"
-	"encoding/json"
 	"fmt"
 	"io/fs"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
 	"github.com/mozillazg/go-unidecode"
 
 	"obsidian-ai-agent/internal/chroma"
+	"obsidian-ai-agent/internal/markdown"
+	"obsidian-ai-agent/internal/tokenizer"
 )
 
 // ChromaClient defines the interface for ChromaDB operations used by the indexer
 type ChromaClient interface {
 	UpsertDocuments(ctx context.Context, documents []chroma.Document) error
+	DeleteDocuments(ctx context.Context, ids []string) error
+	DeleteDocumentsByPath(ctx context.Context, path string, keepIDs []string) error
+	MoveDocuments(ctx context.Context, moves []chroma.DocumentMove) error
 }
 
 // FileIndex represents metadata about an indexed file
@@ -31,56 +40,179 @@ type FileIndex struct {
 	LastModified time.Time `json:"last_modified"`
 	ContentHash  string    `json:"content_hash"`
 	DocumentID   string    `json:"document_id"`
+	ChunkIDs     []string  `json:"chunk_ids,omitempty"`
 	LastIndexed  time.Time `json:"last_indexed"`
+	// Links are the wikilinks and embeds of the note; nil for entries indexed before links were tracked
+	Links []Link `json:"links"`
+	// Transcluded holds the content hashes of the notes embedded into this note, by vault-relative path
+	Transcluded       map[string]string `json:"transcluded,omitempty"`
+	TransclusionDepth int               `json:"transclusion_depth,omitempty"` // Setting the note was indexed with
+	// NormalizerVersion is the markdown.Version the note's text was rendered with
+	NormalizerVersion int `json:"normalizer_version,omitempty"`
+	// ChunkSettings is the fingerprint of the chunking settings the note was indexed with
+	ChunkSettings string `json:"chunk_settings,omitempty"`
 }
 
 // ObsidianIndexer handles indexing of Obsidian markdown files
 type ObsidianIndexer struct {
-	client       ChromaClient
-	batchSize    int
-	vaultPath    string
-	directories  []string
-	indexFile    string
-	fileIndex    map[string]FileIndex
-	chunkSize    int
-	chunkOverlap int
+	mu            sync.Mutex // Serialises indexing runs (e.g. watcher events and periodic scans)
+	client        ChromaClient
+	batchSize     int
+	vaultPath     string
+	filter        *pathFilter // Notes selected for indexing
+	store         StateStore
+	fileIndex     map[string]FileIndex
+	retryFiles    map[string]bool // Files whose upsert failed, retried on the next run
+	ignoreMu      sync.RWMutex    // Guards ignoreRules, which the watcher reads outside indexing runs
+	ignoreRules   ignoreRules     // Default patterns plus the vault's ignore file, reloaded every run
+	graphMu       sync.RWMutex    // Guards graph, which is read outside indexing runs
+	graph         *linkGraph      // Link graph as of the end of the last run
+	embedResolver *noteResolver   // Notes that embeds resolve to during the current run
+	chunkSize     int
+	chunkOverlap  int
+	chunkStrategy ChunkStrategy
+	workers       int
+	upsertWorkers int
+	// tokenizer matches the embedding model; nil disables token sizing and the truncation report
+	tokenizer   tokenizer.Tokenizer
+	tokenSizing bool // chunkSize and chunkOverlap are measured in tokens instead of characters
+	// overlapSentences is the number of sentences repeated in the next chunk with ChunkBySentences
+	overlapSentences int
+	// embedder embeds sentences for ChunkBySemantic; without it sentences are packed by size
+	embedder           Embedder
+	semanticPercentile float64
+	semanticWindow     int
+	semanticMinSize    int
+	enrichment         Enrichment     // Context embedded with the chunks of notes without a profile
+	profiles           []chunkProfile // Chunking settings of the notes matching a profile, first match wins
+	// transclusionDepth is the number of levels of embeds inlined into a note (0 disables transclusion)
+	transclusionDepth int
+	recordQueries     bool // Store dataview, dataviewjs and tasks query sources as note metadata
 }
 
 // Config holds configuration for the Obsidian indexer
 type Config struct {
-	VaultPath    string
-	BatchSize    int
+	VaultPath string
+	BatchSize int
+	// Include lists glob patterns relative to the vault root of the notes to index, e.g. "**/*.md";
+	// patterns starting with "!" exclude notes (default: every note in the vault)
+	Include []string
+	// Exclude lists glob patterns of notes or folders to skip, e.g. "Archive/**"
+	Exclude []string
+	// Directories restricts indexing to these vault folders when Include is empty
+	// (shorthand for the include patterns "<dir>/**/*.md")
 	Directories  []string
 	ChunkSize    int // Target chunk size in characters (default: 2000)
 	ChunkOverlap int // Overlap between chunks in characters (default: 200)
+	// Tokenizer matches the embedding model. When set, every run reports the chunks that are longer
+	// than the model's input limit and are therefore truncated when embedded.
+	Tokenizer tokenizer.Tokenizer
+	// ChunkTokens sizes chunks in tokens of Tokenizer instead of characters, capped at the model's
+	// input limit (default: 0, chunks are sized by ChunkSize)
+	ChunkTokens int
+	// ChunkOverlapTokens is the overlap between chunks in tokens when chunks are sized in tokens
+	ChunkOverlapTokens int
+	// ChunkStrategy chooses how sections longer than a chunk are split (default: ChunkByParagraphs)
+	ChunkStrategy ChunkStrategy
+	// OverlapSentences is the number of trailing sentences repeated in the next chunk with
+	// ChunkBySentences (default: 1)
+	OverlapSentences int
+	// Embedder embeds sentences for ChunkBySemantic, with the embedding function of the collection
+	Embedder Embedder
+	// SemanticPercentile cuts ChunkBySemantic chunks at the gaps between sentences whose similarity
+	// is in the lowest SemanticPercentile percent of the section (default: 10)
+	SemanticPercentile float64
+	// SemanticWindow is the number of sentences compared on either side of a gap (default: 2)
+	SemanticWindow int
+	// SemanticMinSize is the smallest chunk cut at a topic shift, in the unit of the chunk size
+	// (default: a quarter of the chunk size); chunks never grow beyond the chunk size
+	SemanticMinSize int
+	// Enrichment chooses the context embedded with the text of the chunks (default: EnrichFrontmatter)
+	Enrichment Enrichment
+	// Profiles override the chunking settings of the notes matching their paths or tags; the first
+	// matching profile applies and is recorded as "chunk_profile" metadata
+	Profiles []ChunkProfile
+	// Workers is the number of files read and chunked concurrently (default: number of CPUs)
+	Workers int
+	// UpsertWorkers is the number of batches uploaded (and embedded) concurrently (default: 2)
+	UpsertWorkers int
+	// StateStore persists the file index between runs (default: JSON file in the vault root)
+	StateStore StateStore
+	// TransclusionDepth inlines embedded notes and sections ("![[Note#Heading]]") into the embedding
+	// note, following embeds in embedded notes up to this many levels (default: 0, disabled)
+	TransclusionDepth int
+	// RecordQueries stores the sources of dataview, dataviewjs and tasks code blocks as
+	// "query_<language>" metadata instead of discarding them
+	RecordQueries bool
 }
 
 // DefaultConfig returns default indexer configuration
 func DefaultConfig() *Config {
 	return &Config{
-		VaultPath:    ".",
-		BatchSize:    50,
-		Directories:  []string{"notes", "projects"},
-		ChunkSize:    2000,
-		ChunkOverlap: 200,
+		VaultPath:        ".",
+		BatchSize:        50,
+		Include:          []string{defaultIncludePattern},
+		ChunkSize:        2000,
+		ChunkOverlap:     200,
+		ChunkStrategy:    ChunkByParagraphs,
+		OverlapSentences: 1,
+		Enrichment:       EnrichFrontmatter,
+		Workers:          runtime.NumCPU(),
+		UpsertWorkers:    2,
 	}
 }
 
 // NewObsidianIndexer creates a new Obsidian indexer
 func NewObsidianIndexer(client ChromaClient, config *Config) *ObsidianIndexer {
 	indexer := &ObsidianIndexer{
-		client:       client,
-		batchSize:    config.BatchSize,
-		vaultPath:    config.VaultPath,
-		directories:  config.Directories,
-		indexFile:    filepath.Join(config.VaultPath, ".obsidian_index.json"),
-		fileIndex:    make(map[string]FileIndex),
-		chunkSize:    config.ChunkSize,
-		chunkOverlap: config.ChunkOverlap,
+		client:        client,
+		batchSize:     config.BatchSize,
+		vaultPath:     config.VaultPath,
+		store:         config.StateStore,
+		fileIndex:     make(map[string]FileIndex),
+		retryFiles:    make(map[string]bool),
+		chunkSize:     config.ChunkSize,
+		chunkOverlap:  config.ChunkOverlap,
+		chunkStrategy: config.ChunkStrategy,
+		workers:       config.Workers,
+		upsertWorkers: config.UpsertWorkers,
+
+		overlapSentences:   config.OverlapSentences,
+		embedder:           config.Embedder,
+		semanticPercentile: config.SemanticPercentile,
+		semanticWindow:     config.SemanticWindow,
+		semanticMinSize:    config.SemanticMinSize,
+		enrichment:         config.Enrichment,
+		profiles:           compileProfiles(config.Profiles),
+
+		transclusionDepth: config.TransclusionDepth,
+		recordQueries:     config.RecordQueries,
 	}
 
-	// Load existing index
-	indexer.loadFileIndex()
+	if config.Tokenizer != nil {
+		indexer.tokenizer = config.Tokenizer
+		if config.ChunkTokens > 0 {
+			indexer.tokenSizing = true
+			indexer.chunkSize = min(config.ChunkTokens, config.Tokenizer.MaxTokens())
+			indexer.chunkOverlap = config.ChunkOverlapTokens
+		}
+	}
+
+	include := config.Include
+	if len(include) == 0 {
+		include = directoryPatterns(config.Directories)
+	}
+	indexer.filter = newPathFilter(include, config.Exclude)
+
+	// Default to the JSON state file in the vault root
+	if indexer.store == nil {
+		indexer.store = NewJSONStateStore(filepath.Join(config.VaultPath, IndexFileName), config.VaultPath)
+	}
+
+	// Load existing index; runs load it again once they hold the store's lock
+	if err := indexer.loadFileIndex(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
 
 	return indexer
 }
@@ -91,111 +223,182 @@ type IndexResult struct {
 	IndexedFiles    int
 	UpdatedFiles    int
 	SkippedFiles    int
+	FailedFiles     int // Files whose batch failed to upsert; retried on the next run
+	DeletedFiles    int
+	RenamedFiles    int
+	IgnoredFiles    int // Notes that opted out of indexing
+	DeletedChunks   int
+	EmbeddedChunks  int // New and changed chunks that were embedded
+	UnchangedChunks int // Chunks of updated files that kept their embedding; only metadata was updated
 	Errors          []error
 	BatchesUploaded int
+	// TruncatedChunks are the embedded chunks longer than the embedding model's input limit
+	TruncatedChunks []TruncatedChunk
 }
 
-// ReindexVault performs incremental indexing of all markdown files in the specified directories
+// TruncatedChunk is a chunk that the embedding model only embeds the first tokens of
+type TruncatedChunk struct {
+	Path       string
+	ChunkIndex int
+	Tokens     int
+}
+
+// ReindexVault performs incremental indexing of all notes selected by the include and exclude patterns.
+// If directories are given, only the notes in those vault folders are scanned.
 func (idx *ObsidianIndexer) ReindexVault(ctx context.Context, directories []string) (*IndexResult, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
 	result := &IndexResult{
 		Errors: make([]error, 0),
 	}
 
+	unlock, err := idx.lockState()
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+
+	// Start from the stored state, which another process may have saved since the last run
+	if err := idx.loadFileIndex(); err != nil {
+		return result, err
+	}
+
 	log.Println("Starting incremental reindex of vault...")
 
+	idx.loadIgnoreRules()
+
 	// Find all markdown files
-	files, err := idx.findMarkdownFiles(directories)
+	filter := idx.filter
+	if filter == nil {
+		filter = newPathFilter(nil, nil)
+	}
+	if len(directories) > 0 {
+		filter = filter.withIncludes(directoryPatterns(directories))
+	}
+
+	files, err := idx.findMarkdownFiles(filter)
 	if err != nil {
 		return result, fmt.Errorf("failed to find markdown files: %w", err)
 	}
 
 	log.Printf("Found %d markdown files", len(files))
 
-	// Process files in batches
-	documents := make([]chroma.Document, 0, idx.batchSize)
-	batchFiles := make([]string, 0, idx.batchSize) // Track files in current batch
+	// Notes that disappeared since the last run are either renamed or purged
+	vanished := idx.findVanishedFiles(files)
 
-	for _, file := range files {
-		result.ProcessedFiles++
+	idx.indexFiles(ctx, files, vanished, result)
+	idx.finishRun(ctx, vanished, result)
 
-		// Check if file needs indexing
-		needsIndexing, err := idx.fileNeedsIndexing(file)
-		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("failed to check if file needs indexing %s: %w", file, err))
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("indexing cancelled: %w", err)
+	}
+
+	return result, nil
+}
+
+// IndexFiles incrementally indexes the given files, e.g. the paths reported by a filesystem watcher.
+// Paths that no longer exist are treated as deleted files or directories and purged from the collection.
+func (idx *ObsidianIndexer) IndexFiles(ctx context.Context, paths []string) (*IndexResult, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	result := &IndexResult{
+		Errors: make([]error, 0),
+	}
+
+	unlock, err := idx.lockState()
+	if err != nil {
+		return result, err
+	}
+	defer unlock()
+
+	// Start from the stored state, which another process may have saved since the last run
+	if err := idx.loadFileIndex(); err != nil {
+		return result, err
+	}
+
+	var files []string
+	vanished := make(map[string]FileIndex)
+	seen := make(map[string]bool)
+
+	idx.loadIgnoreRules()
+
+	// Retry files whose upsert failed in an earlier run
+	for file := range idx.retryFiles {
+		paths = append(paths, file)
+	}
+
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		if seen[path] {
 			continue
 		}
-
-		if !needsIndexing {
-			result.SkippedFiles++
+		seen[path] = true
+
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			// A removed file or directory: every tracked file at or below the path vanished
+			prefix := path + string(filepath.Separator)
+			for indexed, entry := range idx.fileIndex {
+				if indexed == path || strings.HasPrefix(indexed, prefix) {
+					vanished[indexed] = entry
+				}
+			}
 			continue
 		}
-
-		chunks, fileInfo, err := idx.processFileWithChunks(file)
 		if err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("failed to process file %s: %w", file, err))
+			result.Errors = append(result.Errors, fmt.Errorf("failed to stat %s: %w", path, err))
 			continue
 		}
 
-		if len(chunks) == 0 {
-			log.Printf("Skipping file %s: no content chunks generated", file)
-			continue // Skip empty or invalid files
+		// An ignored or excluded file is purged like a removed one if it was indexed before
+		if idx.isIgnored(path, info.IsDir()) || (!info.IsDir() && !idx.isIncluded(path)) {
+			if entry, exists := idx.fileIndex[path]; exists {
+				vanished[path] = entry
+			}
+			continue
 		}
 
-		// Update metadata for each chunk
-		for i := range chunks {
-			chunks[i].Metadata["last_modified"] = fileInfo.ModTime().Unix()
-			chunks[i].Metadata["content_hash"] = fileInfo.ContentHash
+		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
+			files = append(files, path)
 		}
+	}
 
-		documents = append(documents, chunks...)
-		batchFiles = append(batchFiles, file) // Track which file contributed to this batch
+	// Notes that embed a changed or removed note are re-indexed with its new content
+	files = append(files, idx.findEmbeddingNotes(files, vanished, seen)...)
 
-		// Check if this is an update or new file
-		if _, exists := idx.fileIndex[file]; exists {
-			result.UpdatedFiles++
-		} else {
-			result.IndexedFiles++
-		}
+	if len(files) == 0 && len(vanished) == 0 {
+		return result, nil
+	}
 
-		// Update in-memory index (use first chunk's ID for tracking)
-		idx.fileIndex[file] = FileIndex{
-			Path:         file,
-			LastModified: fileInfo.ModTime(),
-			ContentHash:  fileInfo.ContentHash,
-			DocumentID:   chunks[0].ID,
-			LastIndexed:  time.Now(),
-		}
+	log.Printf("Indexing %d changed files (%d removed)", len(files), len(vanished))
 
-		// Upload batch when full
-		if len(documents) >= idx.batchSize {
-			if err := idx.client.UpsertDocuments(ctx, documents); err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("failed to upsert batch containing files %v: %w", batchFiles, err))
-			} else {
-				result.BatchesUploaded++
-				log.Printf("Upserted batch of %d documents from %d files: %v", len(documents), len(batchFiles), batchFiles)
-			}
-			documents = documents[:0]   // Reset slice
-			batchFiles = batchFiles[:0] // Reset file tracking
-		}
+	idx.indexFiles(ctx, files, vanished, result)
+	idx.finishRun(ctx, vanished, result)
+
+	if err := ctx.Err(); err != nil {
+		return result, fmt.Errorf("indexing cancelled: %w", err)
 	}
 
-	// Upload remaining documents
-	if len(documents) > 0 {
-		if err := idx.client.UpsertDocuments(ctx, documents); err != nil {
-			result.Errors = append(result.Errors, fmt.Errorf("failed to upsert final batch containing files %v: %w", batchFiles, err))
-		} else {
-			result.BatchesUploaded++
-			log.Printf("Upserted final batch of %d documents from %d files: %v", len(documents), len(batchFiles), batchFiles)
-		}
+	return result, nil
+}
+
+// finishRun purges vanished files, persists the file index and logs a summary of the run
+func (idx *ObsidianIndexer) finishRun(ctx context.Context, vanished map[string]FileIndex, result *IndexResult) {
+	// Purge notes that disappeared from the vault and were not renamed
+	if ctx.Err() == nil {
+		idx.purgeDeletedFiles(ctx, vanished, result)
 	}
 
 	// Save updated file index
 	if err := idx.saveFileIndex(); err != nil {
 		result.Errors = append(result.Errors, fmt.Errorf("failed to save file index: %w", err))
 	}
+	idx.rebuildLinkGraph()
 
-	log.Printf("Indexing complete. Processed: %d, New: %d, Updated: %d, Skipped: %d, Batches: %d, Errors: %d",
-		result.ProcessedFiles, result.IndexedFiles, result.UpdatedFiles, result.SkippedFiles, result.BatchesUploaded, len(result.Errors))
+	log.Printf("Indexing complete. Processed: %d, New: %d, Updated: %d, Renamed: %d, Skipped: %d, Ignored: %d, Failed: %d, Deleted: %d, Batches: %d, Chunks embedded: %d, Chunks unchanged: %d, Errors: %d",
+		result.ProcessedFiles, result.IndexedFiles, result.UpdatedFiles, result.RenamedFiles, result.SkippedFiles, result.IgnoredFiles, result.FailedFiles, result.DeletedFiles, result.BatchesUploaded, result.EmbeddedChunks, result.UnchangedChunks, len(result.Errors))
 
 	// Log detailed error information if there were any failures
 	if len(result.Errors) > 0 {
@@ -205,15 +408,162 @@ func (idx *ObsidianIndexer) ReindexVault(ctx context.Context, directories []stri
 		}
 	}
 
-	return result, nil
+	idx.logTruncatedChunks(result)
+}
+
+// findVanishedFiles returns the file index entries of files that are no longer on disk or are now
+// ignored or excluded
+func (idx *ObsidianIndexer) findVanishedFiles(files []string) map[string]FileIndex {
+	found := make(map[string]bool, len(files))
+	for _, file := range files {
+		found[file] = true
+	}
+
+	vanished := make(map[string]FileIndex)
+	for path, entry := range idx.fileIndex {
+		if found[path] {
+			continue
+		}
+
+		// Entries outside the scanned directories may still exist; only track vanished, ignored and excluded files
+		if _, err := os.Stat(path); !os.IsNotExist(err) && !idx.isIgnored(path, false) && idx.isIncluded(path) {
+			continue
+		}
+
+		vanished[path] = entry
+	}
+
+	return vanished
+}
+
+// moveRenamedFile detects whether a new file is a vanished file under a new path and, if so,
+// moves the existing chunks to their new IDs and metadata. It reports whether the move succeeded.
+func (idx *ObsidianIndexer) moveRenamedFile(ctx context.Context, file string, fileInfo *FileWithHash, chunks []chroma.Document, vanished map[string]FileIndex, result *IndexResult) bool {
+	var oldPath string
+	for _, path := range sortedKeys(vanished) {
+		entry := vanished[path]
+		if entry.ContentHash == fileInfo.ContentHash && len(entry.ChunkIDs) == len(chunks) &&
+			entry.NormalizerVersion == markdown.Version && entry.ChunkSettings == fileInfo.ChunkSettings {
+			oldPath = path
+			break
+		}
+	}
+	if oldPath == "" {
+		return false
+	}
+
+	oldEntry := vanished[oldPath]
+	moves := make([]chroma.DocumentMove, len(chunks))
+	chunkIDs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		moves[i] = chroma.DocumentMove{OldID: oldEntry.ChunkIDs[i], Document: chunk}
+		chunkIDs[i] = chunk.ID
+	}
+
+	if err := idx.client.MoveDocuments(ctx, moves); err != nil {
+		// Fall back to regular indexing; the old path is purged with the other vanished files
+		log.Printf("Failed to move chunks of renamed file %s -> %s, re-indexing: %v", oldPath, file, err)
+		return false
+	}
+
+	delete(vanished, oldPath)
+	delete(idx.fileIndex, oldPath)
+	idx.fileIndex[file] = FileIndex{
+		Path:         file,
+		LastModified: fileInfo.ModTime(),
+		ContentHash:  fileInfo.ContentHash,
+		DocumentID:   chunkIDs[0],
+		ChunkIDs:     chunkIDs,
+		LastIndexed:  time.Now(),
+		Links:        fileInfo.Links,
+
+		Transcluded:       fileInfo.Transcluded,
+		TransclusionDepth: idx.transclusionDepth,
+		NormalizerVersion: markdown.Version,
+		ChunkSettings:     fileInfo.ChunkSettings,
+	}
+	result.RenamedFiles++
+	log.Printf("Detected rename %s -> %s, moved %d chunks", oldPath, file, len(chunks))
+
+	return true
+}
+
+// purgeDeletedFiles removes the chunks and index entries of vanished files
+func (idx *ObsidianIndexer) purgeDeletedFiles(ctx context.Context, vanished map[string]FileIndex, result *IndexResult) {
+	for _, path := range sortedKeys(vanished) {
+		if err := idx.deleteFileChunks(ctx, vanished[path], result); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to delete chunks of removed file %s: %w", path, err))
+			continue // Keep the entry so the deletion is retried on the next run
+		}
+
+		delete(idx.fileIndex, path)
+		result.DeletedFiles++
+		log.Printf("Removed chunks of deleted or ignored file %s", path)
+	}
+}
+
+// sortedKeys returns the keys of a file index map in sorted order for deterministic processing
+func sortedKeys(entries map[string]FileIndex) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deleteStaleChunks removes chunks left behind by files that now produce fewer chunks
+func (idx *ObsidianIndexer) deleteStaleChunks(ctx context.Context, staleIDs []string, result *IndexResult) {
+	if len(staleIDs) == 0 {
+		return
+	}
+
+	if err := idx.client.DeleteDocuments(ctx, staleIDs); err != nil {
+		result.Errors = append(result.Errors, fmt.Errorf("failed to delete %d stale chunks: %w", len(staleIDs), err))
+		return
+	}
+
+	result.DeletedChunks += len(staleIDs)
+	log.Printf("Deleted %d stale chunks", len(staleIDs))
+}
+
+// deleteFileChunks removes all chunks recorded for a file index entry
+func (idx *ObsidianIndexer) deleteFileChunks(ctx context.Context, entry FileIndex, result *IndexResult) error {
+	if len(entry.ChunkIDs) == 0 {
+		return idx.client.DeleteDocumentsByPath(ctx, entry.Path, nil)
+	}
+
+	if err := idx.client.DeleteDocuments(ctx, entry.ChunkIDs); err != nil {
+		return err
+	}
+
+	result.DeletedChunks += len(entry.ChunkIDs)
+	return nil
 }
 
-// findMarkdownFiles finds all .md files in the specified directories
-func (idx *ObsidianIndexer) findMarkdownFiles(directories []string) ([]string, error) {
+// staleChunkIDs returns the IDs in previous that are not present in current
+func staleChunkIDs(previous, current []string) []string {
+	currentSet := make(map[string]bool, len(current))
+	for _, id := range current {
+		currentSet[id] = true
+	}
+
+	var stale []string
+	for _, id := range previous {
+		if !currentSet[id] {
+			stale = append(stale, id)
+		}
+	}
+
+	return stale
+}
+
+// findMarkdownFiles finds all .md files selected by the filter that are not ignored
+func (idx *ObsidianIndexer) findMarkdownFiles(filter *pathFilter) ([]string, error) {
 	var files []string
 
-	for _, dir := range directories {
-		dirPath := filepath.Join(idx.vaultPath, dir)
+	for _, root := range filter.roots() {
+		dirPath := filepath.Join(idx.vaultPath, filepath.FromSlash(root))
 
 		// Check if directory exists
 		if _, err := os.Stat(dirPath); os.IsNotExist(err) {
@@ -226,7 +576,22 @@ func (idx *ObsidianIndexer) findMarkdownFiles(directories []string) ([]string, e
 				return err
 			}
 
-			if !d.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
+			if path != dirPath && idx.isIgnored(path, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if d.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".md") {
+				return nil
+			}
+
+			relPath, err := filepath.Rel(dirPath, path)
+			if err != nil {
+				return err
+			}
+			if filter.matches(strings.TrimPrefix(root+"/"+filepath.ToSlash(relPath), "/")) {
 				files = append(files, path)
 			}
 
@@ -245,62 +610,29 @@ func (idx *ObsidianIndexer) findMarkdownFiles(directories []string) ([]string, e
 type FileWithHash struct {
 	os.FileInfo
 	ContentHash string
+	OptedOut    bool              // The note excludes itself from indexing
+	Links       []Link            // Wikilinks and embeds of the note
+	Transcluded map[string]string // Content hashes of the notes embedded into the note
+	// ChunkSettings is the fingerprint of the chunking settings applied to the note
+	ChunkSettings string
 }
 
-// loadFileIndex loads the file index from disk
-func (idx *ObsidianIndexer) loadFileIndex() {
-	data, err := os.ReadFile(idx.indexFile)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Warning: failed to load file index: %v", err)
-		}
-		return
-	}
-
-	var fileMap map[string]FileIndex
-	if err := json.Unmarshal(data, &fileMap); err != nil {
-		log.Printf("Warning: failed to unmarshal file index: %v", err)
-		return
-	}
-
-	idx.fileIndex = fileMap
-	log.Printf("Loaded file index with %d entries", len(idx.fileIndex))
-}
-
-// saveFileIndex saves the file index to disk
-func (idx *ObsidianIndexer) saveFileIndex() error {
-	data, err := json.MarshalIndent(idx.fileIndex, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal file index: %w", err)
-	}
-
-	if err := os.WriteFile(idx.indexFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file index: %w", err)
-	}
-
-	return nil
-}
-
-// fileNeedsIndexing checks if a file needs to be indexed based on modification time and content hash
-func (idx *ObsidianIndexer) fileNeedsIndexing(filePath string) (bool, error) {
+// fileNeedsIndexing checks if a file needs to be indexed based on modification time and content hash.
+// The index entry is passed in so that the check can run concurrently with index updates.
+func (idx *ObsidianIndexer) fileNeedsIndexing(filePath string, indexEntry FileIndex, exists bool) (bool, error) {
 	// Get file info
-	fileInfo, err := os.Stat(filePath)
+	_, err := os.Stat(filePath)
 	if err != nil {
 		return false, fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	// Check if file is in index
-	indexEntry, exists := idx.fileIndex[filePath]
 	if !exists {
 		return true, nil // New file, needs indexing
 	}
 
-	// Check if modification time changed
-	if !fileInfo.ModTime().Equal(indexEntry.LastModified) {
-		return true, nil // File modified, needs re-indexing
-	}
-
-	// For files with same modification time, check content hash
+	// A changed modification time alone does not trigger re-indexing: entries rebuilt from the
+	// collection only have second precision, and touched files keep their embeddings
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return false, fmt.Errorf("failed to read file for hash check: %w", err)
@@ -311,11 +643,31 @@ func (idx *ObsidianIndexer) fileNeedsIndexing(filePath string) (bool, error) {
 		return true, nil // Content changed, needs re-indexing
 	}
 
+	// Notes rendered by an older markdown normaliser are embedded again
+	if indexEntry.NormalizerVersion != markdown.Version {
+		return true, nil
+	}
+
+	// Notes are chunked again when the settings that apply to them change
+	if indexEntry.ChunkSettings != idx.noteChunkSettings(filePath, string(content)).fingerprint(idx) {
+		return true, nil
+	}
+
+	// Notes with embeds are re-indexed when transclusion is reconfigured or an embedded note changes
+	if hasEmbeds(indexEntry.Links) && indexEntry.TransclusionDepth != idx.transclusionDepth {
+		return true, nil
+	}
+	if idx.transclusionsChanged(indexEntry) {
+		return true, nil
+	}
+
 	return false, nil // File unchanged, skip indexing
 }
 
-// generateChunkID creates a unique ID for a chunk based on file path and chunk index
-func generateChunkID(filePath string, chunkIndex int) string {
+// generateChunkID creates an ID for a chunk from its file path and the text that is embedded, so a
+// chunk keeps its ID (and embedding) when other chunks of the note change. occurrence numbers the
+// chunks of a note with the same text.
+func generateChunkID(filePath, content string, occurrence int) string {
 	// Clean and normalize the path
 	cleanPath := filepath.Clean(filePath)
 
@@ -323,8 +675,11 @@ func generateChunkID(filePath string, chunkIndex int) string {
 	// This prevents issues with files that have accented characters in their names
 	normalizedPath := normalizeUnicode(cleanPath)
 
-	// Create MD5 hash of the normalized path and chunk index for consistent ID generation
-	chunkKey := fmt.Sprintf("%s_chunk_%d", normalizedPath, chunkIndex)
+	// Create MD5 hash of the normalized path and content for consistent ID generation
+	chunkKey := fmt.Sprintf("%s_chunk_%s", normalizedPath, content)
+	if occurrence > 0 {
+		chunkKey += fmt.Sprintf("_%d", occurrence)
+	}
 	hash := md5.Sum([]byte(chunkKey))
 	return fmt.Sprintf("%x", hash)
 }
@@ -380,6 +735,11 @@ func (idx *ObsidianIndexer) processFileWithChunks(filePath string) ([]chroma.Doc
 		contentStr = strings.ToValidUTF8(contentStr, "")
 	}
 
+	// Comments are private scratch text: keep them out of links, tags and embeddings
+	contentStr = markdown.StripComments(contentStr)
+
+	fileWithHash.Links = extractLinks(contentStr)
+
 	// Skip files that are too short
 	if len(strings.TrimSpace(contentStr)) < 10 {
 		return nil, fileWithHash, nil
@@ -388,217 +748,89 @@ func (idx *ObsidianIndexer) processFileWithChunks(filePath string) ([]chroma.Doc
 	// Extract frontmatter and enhance content before processing
 	enhancedContent, frontmatterMetadata := idx.enhanceContentWithFrontmatter(contentStr, filePath)
 
-	// Clean enhanced content before chunking
-	cleanedContent := idx.cleanContent(enhancedContent)
-
-	// Split content into chunks
-	chunks := idx.chunkContent(cleanedContent, filePath)
-
-	// Add frontmatter metadata to each chunk
-	for i := range chunks {
-		// Merge frontmatter metadata with existing chunk metadata
-		for key, value := range frontmatterMetadata {
-			// Convert arrays to strings for ChromaDB compatibility
-			chunks[i].Metadata[key] = idx.convertMetadataValue(value)
-		}
+	// Skip notes that opt out with "index: false" or a noindex tag
+	if optsOutOfIndexing(frontmatterMetadata) {
+		fileWithHash.OptedOut = true
+		return nil, fileWithHash, nil
 	}
 
-	return chunks, fileWithHash, nil
-}
-
-// chunkContent splits markdown content into semantic chunks
-func (idx *ObsidianIndexer) chunkContent(content string, filePath string) []chroma.Document {
-	var chunks []chroma.Document
-
-	// First try to split by headers
-	headerChunks := idx.splitByHeaders(content)
-
-	for i, chunk := range headerChunks {
-		// If chunk is still too large, split it further
-		if len(chunk) > idx.chunkSize {
-			subChunks := idx.splitBySize(chunk, idx.chunkSize, idx.chunkOverlap)
-			for j, subChunk := range subChunks {
-				chunkIndex := i*1000 + j // Ensure unique indexing
-				doc := chroma.Document{
-					ID:      generateChunkID(filePath, chunkIndex),
-					Content: subChunk,
-					Metadata: map[string]interface{}{
-						"path":        filePath,
-						"filename":    filepath.Base(filePath),
-						"folder":      filepath.Dir(filePath),
-						"chunk_index": chunkIndex,
-						"chunk_type":  "sub_header",
-					},
-				}
-				chunks = append(chunks, doc)
-			}
-		} else {
-			doc := chroma.Document{
-				ID:      generateChunkID(filePath, i),
-				Content: chunk,
-				Metadata: map[string]interface{}{
-					"path":        filePath,
-					"filename":    filepath.Base(filePath),
-					"folder":      filepath.Dir(filePath),
-					"chunk_index": i,
-					"chunk_type":  "header",
-				},
-			}
-			chunks = append(chunks, doc)
-		}
+	tags, _ := frontmatterMetadata["tags"].([]string)
+	settings := idx.chunkSettingsFor(filePath, tags)
+	fileWithHash.ChunkSettings = settings.fingerprint(idx)
+	if settings.enrichment == EnrichNone {
+		_, enhancedContent = idx.extractFrontmatter(contentStr) // Embed the body without the frontmatter text
 	}
 
-	// If no header-based chunks were created, fall back to size-based chunking
-	if len(chunks) == 0 {
-		sizeChunks := idx.splitBySize(content, idx.chunkSize, idx.chunkOverlap)
-		for i, chunk := range sizeChunks {
-			doc := chroma.Document{
-				ID:      generateChunkID(filePath, i),
-				Content: chunk,
-				Metadata: map[string]interface{}{
-					"path":        filePath,
-					"filename":    filepath.Base(filePath),
-					"folder":      filepath.Dir(filePath),
-					"chunk_index": i,
-					"chunk_type":  "size",
-				},
-			}
-			chunks = append(chunks, doc)
+	// Dataview inline fields are note metadata like frontmatter, which takes precedence
+	_, body, _ := splitYAMLFrontmatter(contentStr)
+	for key, value := range inlineFieldMetadata(extractInlineFields(body)) {
+		if _, exists := frontmatterMetadata[key]; !exists && !listFrontmatterKeys[key] {
+			frontmatterMetadata[key] = value
 		}
 	}
 
-	return chunks
-}
-
-// splitByHeaders splits content by markdown headers
-func (idx *ObsidianIndexer) splitByHeaders(content string) []string {
-	// Split by headers (# ## ### etc.)
-	headerRegex := regexp.MustCompile(`(?m)^(#{1,6})\s+(.+)$`)
-
-	// Find all header positions
-	matches := headerRegex.FindAllStringIndex(content, -1)
-	if len(matches) == 0 {
-		// No headers found, return entire content
-		return []string{content}
-	}
-
-	var chunks []string
-	start := 0
-
-	for i, match := range matches {
-		// Add content before this header (if any)
-		if match[0] > start {
-			chunk := strings.TrimSpace(content[start:match[0]])
-			if len(chunk) > 0 {
-				chunks = append(chunks, chunk)
-			}
-		}
-
-		// Determine the end of this section
-		var end int
-		if i+1 < len(matches) {
-			end = matches[i+1][0] // Next header start
-		} else {
-			end = len(content) // End of content
-		}
-
-		// Add this header section
-		chunk := strings.TrimSpace(content[match[0]:end])
-		if len(chunk) > 0 {
-			chunks = append(chunks, chunk)
-		}
-
-		start = end
+	var queries map[string]interface{}
+	if idx.recordQueries {
+		queries = queryMetadata(extractQueryBlocks(body))
 	}
 
-	return chunks
-}
+	// Inline the notes and sections embedded with ![[...]]
+	enhancedContent, fileWithHash.Transcluded = idx.expandTransclusions(filePath, enhancedContent)
 
-// splitBySize splits content into size-based chunks with overlap
-func (idx *ObsidianIndexer) splitBySize(content string, chunkSize, overlap int) []string {
-	if len(content) <= chunkSize {
-		return []string{content}
-	}
+	// Remember callouts and highlights for the chunk metadata
+	markup := parseObsidianMarkup(enhancedContent)
 
-	var chunks []string
-	start := 0
+	// Split content into chunks while its structure is intact; chunks are cleaned individually
+	chunks := idx.chunkNote(enhancedContent, filePath, settings)
 
-	// Minimum meaningful chunk size to prevent tiny chunks at boundaries
-	minChunkSize := 50 // Minimum 50 characters for meaningful content
+	// Index checklist tasks as documents of their own
+	chunks = append(chunks, idx.taskDocuments(filePath, contentStr)...)
+	numberDuplicateChunks(filePath, chunks)
 
-	for start < len(content) {
-		end := start + chunkSize
-		if end > len(content) {
-			end = len(content)
-		}
+	// Record the lines, headings and block IDs of each chunk for deep links into the note
+	idx.anchorChunks(chunks, idx.newSourceMap(contentStr))
 
-		// Try to break at word boundary
-		if end < len(content) {
-			// Look for last space within reasonable distance
-			for i := end; i > end-100 && i > start; i-- {
-				if content[i] == ' ' || content[i] == '\n' {
-					end = i
-					break
-				}
+	// Add frontmatter metadata to each chunk
+	for i := range chunks {
+		// Merge frontmatter metadata with existing chunk metadata
+		for key, value := range frontmatterMetadata {
+			if key == "tags" {
+				continue // Stored below with nested tags expanded
+			}
+			// Convert arrays to strings and dates to timestamps for ChromaDB compatibility
+			if converted := idx.convertMetadataValue(value); converted != nil {
+				chunks[i].Metadata[metadataKey(key)] = converted
 			}
 		}
 
-		chunk := strings.TrimSpace(content[start:end])
-		if len(chunk) > 0 {
-			chunks = append(chunks, chunk)
+		// Make every tag, including the parents of nested tags, filterable
+		for key, value := range tagMetadata(tags) {
+			chunks[i].Metadata[key] = value
 		}
 
-		// Calculate next start position with overlap
-		nextStart := end - overlap
-
-		// Prevent cascading tiny chunks at document boundaries
-		if nextStart <= start {
-			// If overlap would cause us to go backwards or stay in place,
-			// check if the remaining content is small enough to append to current chunk
-			remainingContent := len(content) - end
-			if remainingContent <= minChunkSize && len(chunks) > 0 {
-				// Append remaining small content to the last chunk
-				remainingText := strings.TrimSpace(content[end:])
-				if len(remainingText) > 0 {
-					lastChunk := chunks[len(chunks)-1]
-					chunks[len(chunks)-1] = lastChunk + " " + remainingText
-				}
-				break
-			}
-			// Otherwise ensure we make minimal progress (but still prevent infinite loop)
-			nextStart = start + 1
+		if settings.profile != "" {
+			chunks[i].Metadata["chunk_profile"] = settings.profile
 		}
 
-		// Additional check: if remaining content would create cascading tiny chunks, stop
-		remainingFromNext := len(content) - nextStart
-		if remainingFromNext <= minChunkSize {
-			// Append remaining content to the last chunk instead of creating tiny chunks
-			if len(chunks) > 0 && remainingFromNext > 0 {
-				lastChunk := chunks[len(chunks)-1]
-				remainingText := strings.TrimSpace(content[nextStart:])
-				if len(remainingText) > 0 {
-					// Only append if it's not already included (avoid duplication)
-					if !strings.HasSuffix(lastChunk, remainingText) {
-						chunks[len(chunks)-1] = lastChunk + " " + remainingText
-					}
-				}
-			}
-			break
+		for key, value := range idx.markupMetadata(markup, chunks[i].Content) {
+			chunks[i].Metadata[key] = value
 		}
 
-		start = nextStart
-
-		// Safety check: if we're at the end, break
-		if start >= len(content) {
-			break
+		for key, value := range queries {
+			chunks[i].Metadata[key] = value
 		}
 	}
 
-	return chunks
+	return chunks, fileWithHash, nil
 }
 
-// extractFrontmatter parses Obsidian-style frontmatter and returns structured data plus body content
+// extractFrontmatter parses YAML frontmatter or the legacy Obsidian-style header and returns
+// structured data plus body content
 func (idx *ObsidianIndexer) extractFrontmatter(content string) (map[string]interface{}, string) {
+	if frontmatter, body, ok := idx.extractYAMLFrontmatter(content); ok {
+		return frontmatter, body
+	}
+
 	frontmatter := make(map[string]interface{})
 
 	// Check if content starts with frontmatter (no YAML --- markers in Obsidian style)
@@ -615,8 +847,8 @@ func (idx *ObsidianIndexer) extractFrontmatter(content string) (map[string]inter
 		}
 	}
 
-	// If no separator found, treat entire content as body
-	if separatorIndex == -1 {
+	// If no separator found, or the lines above it are not a header, treat entire content as body
+	if separatorIndex == -1 || !isLegacyHeader(lines[:separatorIndex]) {
 		return frontmatter, content
 	}
 
@@ -649,6 +881,10 @@ func (idx *ObsidianIndexer) extractFrontmatter(content string) (map[string]inter
 					if tags := idx.parseTags(value); len(tags) > 0 {
 						frontmatter["tags"] = tags
 					}
+				case "index":
+					if value != "" {
+						frontmatter["index"] = value
+					}
 				}
 			}
 		}
@@ -662,6 +898,33 @@ func (idx *ObsidianIndexer) extractFrontmatter(content string) (map[string]inter
 	return frontmatter, body
 }
 
+var (
+	// legacyHeaderIDRegex matches the numeric ID on the first line of the legacy header
+	legacyHeaderIDRegex = regexp.MustCompile(`^\d+$`)
+	// legacyHeaderLineRegex matches the key lines of the legacy header
+	legacyHeaderLineRegex = regexp.MustCompile(`(?i)^(categories|tags|index):`)
+)
+
+// isLegacyHeader reports whether the lines above the first "---" form a legacy header: an optional
+// numeric ID on the first line and Categories:, Tags: and Index: lines. Anything else means the
+// separator is an ordinary thematic break.
+func isLegacyHeader(lines []string) bool {
+	found := false
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case i == 0 && legacyHeaderIDRegex.MatchString(line):
+		case legacyHeaderLineRegex.MatchString(line):
+		default:
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
 // parseCategories extracts categories from Obsidian-style [[Category]] format
 func (idx *ObsidianIndexer) parseCategories(value string) []string {
 	var categories []string
@@ -682,7 +945,7 @@ func (idx *ObsidianIndexer) parseCategories(value string) []string {
 	return categories
 }
 
-// parseTags extracts tags from comma-separated format
+// parseTags extracts normalised tags from comma-separated format
 func (idx *ObsidianIndexer) parseTags(value string) []string {
 	var tags []string
 
@@ -699,7 +962,7 @@ func (idx *ObsidianIndexer) parseTags(value string) []string {
 		}
 	}
 
-	return tags
+	return normalizeTags(tags)
 }
 
 // frontmatterToContent converts frontmatter metadata to readable content
@@ -725,10 +988,15 @@ func (idx *ObsidianIndexer) frontmatterToContent(frontmatter map[string]interfac
 		parts = append(parts, fmt.Sprintf("Tags: %s.", tagList))
 	}
 
+	// Add aliases if present, so searches for alternative names find the note
+	if aliases, ok := frontmatter["aliases"].([]string); ok && len(aliases) > 0 {
+		parts = append(parts, fmt.Sprintf("Also known as: %s.", strings.Join(aliases, ", ")))
+	}
+
 	return strings.Join(parts, " ")
 }
 
-// extractFolderCategories extracts ALL folder levels as categories from file path using vault configuration
+// extractFolderCategories extracts ALL folder levels below the include pattern root as categories from file path
 func (idx *ObsidianIndexer) extractFolderCategories(filePath string) []string {
 	categories := make([]string, 0) // Initialize as empty slice, not nil
 
@@ -770,23 +1038,22 @@ func (idx *ObsidianIndexer) extractFolderCategories(filePath string) []string {
 		return categories
 	}
 
-	// Check if first folder is one of our configured directories
-	firstFolder := cleanParts[0]
-	isConfiguredDir := false
-	for _, dir := range idx.directories {
-		if strings.EqualFold(firstFolder, dir) {
-			isConfiguredDir = true
-			break
-		}
+	// Folders of the include pattern's root (e.g. "Projects" for "Projects/**/*.md") are not categories
+	var root string
+	if idx.filter != nil {
+		root, _ = idx.filter.includeRoot(filepath.ToSlash(relPath))
 	}
-
-	// If file is in a configured directory, extract ALL folder levels after it
-	if isConfiguredDir && len(cleanParts) > 1 {
-		// Add all folders between configured directory and file as categories
-		categories = append(categories, cleanParts[1:]...)
+	if root != "" {
+		for _, rootPart := range strings.Split(root, "/") {
+			if len(cleanParts) == 0 || !strings.EqualFold(cleanParts[0], rootPart) {
+				break
+			}
+			cleanParts = cleanParts[1:]
+		}
 	}
 
-	return categories
+	// Every remaining folder level is a category
+	return append(categories, cleanParts...)
 }
 
 // enhanceContentWithFrontmatter combines frontmatter extraction, folder categories, and content enhancement
@@ -794,6 +1061,12 @@ func (idx *ObsidianIndexer) enhanceContentWithFrontmatter(content string, filePa
 	// Extract frontmatter from content
 	frontmatter, bodyContent := idx.extractFrontmatter(content)
 
+	// Merge inline #tags from the body with the frontmatter tags
+	fmTags, _ := frontmatter["tags"].([]string)
+	if tags := mergeTags(fmTags, extractInlineTags(bodyContent)); len(tags) > 0 {
+		frontmatter["tags"] = tags
+	}
+
 	// Extract folder-based categories
 	folderCategories := idx.extractFolderCategories(filePath)
 
@@ -825,54 +1098,40 @@ func (idx *ObsidianIndexer) enhanceContentWithFrontmatter(content string, filePa
 	return enhancedContent, frontmatter
 }
 
-// convertMetadataValue converts array values to strings for ChromaDB compatibility
+// convertMetadataValue converts frontmatter values to the types supported by ChromaDB metadata:
+// lists become comma-separated strings and dates become unix timestamps
 func (idx *ObsidianIndexer) convertMetadataValue(value interface{}) interface{} {
-	// Convert []string to comma-separated string for ChromaDB compatibility
-	if strSlice, ok := value.([]string); ok {
-		if len(strSlice) == 0 {
-			return ""
-		}
-		return strings.Join(strSlice, ", ")
+	switch v := value.(type) {
+	case []string:
+		// Convert []string to comma-separated string for ChromaDB compatibility
+		return strings.Join(v, ", ")
+	case []interface{}:
+		return strings.Join(frontmatterList(v), ", ")
+	case time.Time:
+		return v.Unix()
+	case uint:
+		return convertUnsigned(uint64(v))
+	case uint64:
+		return convertUnsigned(v)
+	case map[string]interface{}:
+		return nil // Nested maps are flattened when the frontmatter is parsed
 	}
 
 	// Return other types as-is (strings, numbers, booleans are supported by ChromaDB)
 	return value
 }
 
-// cleanContent removes URLs and other problematic content that can cause tokenization issues
-func (idx *ObsidianIndexer) cleanContent(content string) string {
-	// Remove dataview blocks (```dataview ... ```)
-	dataviewRegex := regexp.MustCompile(`(?s)` + "```dataview.*?```")
-	content = dataviewRegex.ReplaceAllString(content, "")
-
-	// Remove YAML frontmatter
-	frontmatterRegex := regexp.MustCompile(`(?s)^---.*?---\s*`)
-	content = frontmatterRegex.ReplaceAllString(content, "")
-
-	// Remove repetitive headers (Related Notes/References) that appear in many documents
-	repetitiveHeaderRegex := regexp.MustCompile(`(?mi)^#+\s*(Related Notes?|References?)\s*$`)
-	content = repetitiveHeaderRegex.ReplaceAllString(content, "")
-
-	// Remove markdown links but keep link text: [text](url) -> text
-	// This must be done BEFORE removing standalone URLs
-	markdownLinkRegex := regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
-	content = markdownLinkRegex.ReplaceAllString(content, "$1")
-
-	// Remove Obsidian wikilinks but keep link text: [[text]] -> text
-	wikiLinkRegex := regexp.MustCompile(`\[\[([^\]]+)\]\]`)
-	content = wikiLinkRegex.ReplaceAllString(content, "$1")
-
-	// Remove standalone URLs (http/https) - after processing markdown links
-	urlRegex := regexp.MustCompile(`https?://[^\s\)]+`)
-	content = urlRegex.ReplaceAllString(content, "")
-
-	// Remove excessive whitespace and normalize
-	content = regexp.MustCompile(`\s+`).ReplaceAllString(content, " ")
-	content = strings.TrimSpace(content)
-
-	// Normalize Unicode characters to ASCII equivalents to ensure consistent tokenization
-	// This prevents tensor shape mismatches in ChromaDB embedding
-	content = normalizeUnicode(content)
+// convertUnsigned converts an unsigned integer to int64, or to its decimal string when it is too
+// large for int64
+func convertUnsigned(v uint64) interface{} {
+	if v > math.MaxInt64 {
+		return strconv.FormatUint(v, 10)
+	}
+	return int64(v)
+}
 
-	return content
+// cleanContent renders markdown as the plain text that is embedded, the same way queries are
+// normalised by the HTTP server
+func (idx *ObsidianIndexer) cleanContent(content string) string {
+	return markdown.Normalize(content, markdown.EmbeddingOptions)
 }
@@ -0,0 +1,434 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"obsidian-ai-agent/internal/chroma"
+	"obsidian-ai-agent/internal/markdown"
+)
+
+// fileJob is a file queued for reading and chunking, with a snapshot of its index entry
+type fileJob struct {
+	seq    int
+	file   string
+	entry  FileIndex
+	exists bool
+}
+
+// fileOutcome is the result of reading and chunking a single file
+type fileOutcome struct {
+	seq           int
+	file          string
+	needsIndexing bool
+	links         []Link // Links of an unchanged file whose entry predates link tracking
+	chunks        []chroma.Document
+	changed       []chroma.Document // Chunks that are new since the last run and need embedding
+	kept          []chroma.Document // Chunks whose text is unchanged; only their metadata is updated
+	truncated     []TruncatedChunk  // Changed chunks longer than the embedding model's input limit
+	fileInfo      *FileWithHash
+	err           error
+}
+
+// pendingFile is a file index entry that is committed once its batch has been upserted
+type pendingFile struct {
+	entry FileIndex
+	isNew bool
+}
+
+// uploadBatch is a batch of chunks handed to the upsert workers
+type uploadBatch struct {
+	seq       int
+	documents []chroma.Document // New and changed chunks, embedded when they are upserted
+	kept      []chroma.Document // Unchanged chunks that keep their embedding
+	files     []string          // Files that contributed to this batch
+	pending   []pendingFile     // Index entries to commit when the upsert succeeds
+	staleIDs  []string          // Chunk IDs that no longer exist after re-chunking
+	// stalePaths are files indexed before chunk IDs were tracked, whose previous chunks are
+	// deleted by path
+	stalePaths []string
+	final      bool
+}
+
+// indexFiles runs the indexing pipeline over the given files:
+//
+//  1. up to Workers goroutines check, read and chunk files concurrently
+//  2. a single coordinator consumes their results in file order, detects renames and
+//     groups chunks into batches
+//  3. up to UpsertWorkers goroutines upload (and thereby embed) batches concurrently
+//
+// Chunk IDs are derived from the chunk text, so of a modified note only the new and changed
+// chunks are embedded; unchanged chunks only get their metadata (e.g. chunk_index) updated.
+//
+// Files are only committed to the file index after their batch was upserted successfully;
+// files of failed batches are queued for retry. Results are consumed and batch outcomes
+// applied in a fixed order, so the IndexResult accounting is the same as for a sequential
+// run regardless of scheduling. Cancelling ctx stops the pipeline between files and batches.
+func (idx *ObsidianIndexer) indexFiles(ctx context.Context, files []string, vanished map[string]FileIndex, result *IndexResult) {
+	workers := max(idx.workers, 1)
+	upsertWorkers := max(idx.upsertWorkers, 1)
+
+	if idx.transclusionDepth > 0 {
+		idx.embedResolver = idx.newEmbedResolver(files, vanished)
+	}
+
+	// Snapshot index entries up front: the coordinator updates the index while workers read
+	jobList := make([]fileJob, len(files))
+	for i, file := range files {
+		entry, exists := idx.fileIndex[file]
+		jobList[i] = fileJob{seq: i, file: file, entry: entry, exists: exists}
+	}
+
+	// Bound the number of chunked files waiting for the coordinator
+	window := workers * 4
+	slots := make(chan struct{}, window)
+	jobs := make(chan fileJob)
+	outcomes := make(chan fileOutcome, window)
+
+	go func() {
+		defer close(jobs)
+		for _, job := range jobList {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var readers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for job := range jobs {
+				if ctx.Err() != nil {
+					continue // Drain remaining jobs without reading files
+				}
+				outcomes <- idx.readFile(job)
+			}
+		}()
+	}
+
+	go func() {
+		readers.Wait()
+		close(outcomes)
+	}()
+
+	// Upsert workers record the outcome of every batch by sequence number
+	batches := make(chan uploadBatch)
+	var uploaded []uploadBatch
+	batchErrors := make(map[int]error)
+	batchEmbedded := make(map[int]int)
+	var batchMu sync.Mutex
+	var uploaders sync.WaitGroup
+	for i := 0; i < upsertWorkers; i++ {
+		uploaders.Add(1)
+		go func() {
+			defer uploaders.Done()
+			for batch := range batches {
+				embedded, err := 0, ctx.Err()
+				if err == nil {
+					embedded, err = idx.uploadBatch(ctx, batch)
+				}
+				batchMu.Lock()
+				batchErrors[batch.seq] = err
+				batchEmbedded[batch.seq] = embedded
+				batchMu.Unlock()
+			}
+		}()
+	}
+
+	// Process files in batches
+	current := &uploadBatch{}
+	sendBatch := func(final bool) {
+		current.seq = len(uploaded)
+		current.final = final
+		uploaded = append(uploaded, *current)
+		batches <- *current
+
+		// Start a fresh batch: the previous one is now owned by the upsert workers
+		current = &uploadBatch{}
+	}
+
+	pending := make(map[int]fileOutcome)
+	next := 0
+	for outcome := range outcomes {
+		pending[outcome.seq] = outcome
+
+		// Consume results in file order; after cancellation only drain the remaining outcomes
+		for ctx.Err() == nil {
+			outcome, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+
+			if idx.handleFileOutcome(ctx, outcome, vanished, result, current) && len(current.documents)+len(current.kept) >= idx.batchSize {
+				sendBatch(false) // Upload batch when full
+			}
+		}
+	}
+
+	// Upload remaining documents
+	if len(current.files) > 0 && ctx.Err() == nil {
+		sendBatch(true)
+	}
+
+	close(batches)
+	uploaders.Wait()
+
+	// Apply batch outcomes in upload order
+	for _, batch := range uploaded {
+		label := "batch"
+		if batch.final {
+			label = "final batch"
+		}
+
+		if err := batchErrors[batch.seq]; err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to upsert %s containing files %v: %w", label, batch.files, err))
+			// Leave the previous index state untouched so these files are retried
+			result.FailedFiles += len(batch.pending)
+			for _, file := range batch.files {
+				idx.retryFiles[file] = true
+			}
+			continue
+		}
+
+		// Commit the files of this batch to the index
+		for _, file := range batch.pending {
+			idx.fileIndex[file.entry.Path] = file.entry
+			delete(idx.retryFiles, file.entry.Path)
+			if file.isNew {
+				result.IndexedFiles++
+			} else {
+				result.UpdatedFiles++
+			}
+		}
+
+		embedded := batchEmbedded[batch.seq]
+		result.EmbeddedChunks += embedded
+		result.UnchangedChunks += len(batch.documents) + len(batch.kept) - embedded
+		result.BatchesUploaded++
+		log.Printf("Upserted %s of %d chunks (%d embedded) from %d files: %v", label, len(batch.documents)+len(batch.kept), embedded, len(batch.files), batch.files)
+		idx.deleteStaleChunks(ctx, batch.staleIDs, result)
+		idx.deleteStalePaths(ctx, batch.stalePaths, result)
+	}
+}
+
+// deleteStalePaths removes the previous chunks of files indexed before chunk IDs were tracked,
+// keeping the chunks they were just indexed with
+func (idx *ObsidianIndexer) deleteStalePaths(ctx context.Context, paths []string, result *IndexResult) {
+	for _, path := range paths {
+		if err := idx.client.DeleteDocumentsByPath(ctx, path, idx.fileIndex[path].ChunkIDs); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("failed to delete previous chunks of %s: %w", path, err))
+		}
+	}
+}
+
+// readFile checks whether a file needs indexing and, if so, reads and chunks it.
+// It only reads indexer configuration and is safe to call from multiple goroutines.
+func (idx *ObsidianIndexer) readFile(job fileJob) fileOutcome {
+	outcome := fileOutcome{seq: job.seq, file: job.file}
+
+	// Check if file needs indexing
+	needsIndexing, err := idx.fileNeedsIndexing(job.file, job.entry, job.exists)
+	if err != nil {
+		outcome.err = fmt.Errorf("failed to check if file needs indexing %s: %w", job.file, err)
+		return outcome
+	}
+
+	outcome.needsIndexing = needsIndexing
+	if !needsIndexing {
+		// Backfill the links of entries indexed before links were tracked
+		if job.entry.Links == nil {
+			if content, err := os.ReadFile(job.file); err == nil {
+				outcome.links = extractLinks(strings.ToValidUTF8(string(content), ""))
+			}
+		}
+		return outcome
+	}
+
+	chunks, fileInfo, err := idx.processFileWithChunks(job.file)
+	if err != nil {
+		outcome.err = fmt.Errorf("failed to process file %s: %w", job.file, err)
+		return outcome
+	}
+
+	// Update metadata for each chunk
+	for i := range chunks {
+		chunks[i].Metadata["last_modified"] = fileInfo.ModTime().Unix()
+		chunks[i].Metadata["content_hash"] = fileInfo.ContentHash
+		chunks[i].Metadata["normalizer_version"] = markdown.Version
+		chunks[i].Metadata["chunk_settings"] = fileInfo.ChunkSettings
+	}
+
+	outcome.chunks = chunks
+	outcome.changed, outcome.kept = chunks, nil
+	if job.exists {
+		outcome.changed, outcome.kept = splitKeptChunks(chunks, job.entry.ChunkIDs)
+	}
+	outcome.truncated = idx.truncatedChunks(outcome.changed)
+	outcome.fileInfo = fileInfo
+	return outcome
+}
+
+// handleFileOutcome records a processed file in the result and file index and appends its
+// chunks to the batch. It reports whether the file was added to the batch.
+func (idx *ObsidianIndexer) handleFileOutcome(ctx context.Context, outcome fileOutcome, vanished map[string]FileIndex, result *IndexResult, batch *uploadBatch) bool {
+	file := outcome.file
+	result.ProcessedFiles++
+
+	if outcome.err != nil {
+		result.Errors = append(result.Errors, outcome.err)
+		return false
+	}
+
+	if !outcome.needsIndexing {
+		result.SkippedFiles++
+		if entry, exists := idx.fileIndex[file]; exists && outcome.links != nil {
+			entry.Links = outcome.links
+			idx.fileIndex[file] = entry
+		}
+		return false
+	}
+
+	chunks, fileInfo := outcome.chunks, outcome.fileInfo
+
+	if fileInfo.OptedOut {
+		log.Printf("Skipping file %s: opted out of indexing", file)
+		result.IgnoredFiles++
+		// Purge a previously indexed version of this note
+		if entry, exists := idx.fileIndex[file]; exists {
+			if err := idx.deleteFileChunks(ctx, entry, result); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("failed to delete chunks of opted-out file %s: %w", file, err))
+			} else {
+				delete(idx.fileIndex, file)
+				result.DeletedFiles++
+			}
+		}
+		return false
+	}
+
+	if len(chunks) == 0 {
+		log.Printf("Skipping file %s: no content chunks generated", file)
+		// Drop the chunks of a previously indexed version of this file
+		if entry, exists := idx.fileIndex[file]; exists {
+			if err := idx.deleteFileChunks(ctx, entry, result); err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("failed to delete chunks of emptied file %s: %w", file, err))
+			} else {
+				delete(idx.fileIndex, file)
+			}
+		}
+		return false // Skip empty or invalid files
+	}
+
+	// A new path with the content of a vanished file is a rename: move its chunks without re-embedding
+	if _, exists := idx.fileIndex[file]; !exists {
+		if idx.moveRenamedFile(ctx, file, fileInfo, chunks, vanished, result) {
+			return false
+		}
+	}
+
+	batch.documents = append(batch.documents, outcome.changed...)
+	batch.kept = append(batch.kept, outcome.kept...)
+	batch.files = append(batch.files, file) // Track which file contributed to this batch
+	result.TruncatedChunks = append(result.TruncatedChunks, outcome.truncated...)
+
+	chunkIDs := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		chunkIDs[i] = chunk.ID
+	}
+
+	// Find chunks of the previous version that the new chunking no longer produces
+	if entry, exists := idx.fileIndex[file]; exists {
+		if len(entry.ChunkIDs) == 0 {
+			// Entries written before chunk IDs were tracked: clear by path once the batch is upserted
+			batch.stalePaths = append(batch.stalePaths, file)
+		} else {
+			batch.staleIDs = append(batch.staleIDs, staleChunkIDs(entry.ChunkIDs, chunkIDs)...)
+		}
+	}
+
+	// Queue the index update until the batch is upserted (first chunk's ID is kept for backwards compatibility)
+	_, exists := idx.fileIndex[file]
+	batch.pending = append(batch.pending, pendingFile{
+		entry: FileIndex{
+			Path:         file,
+			LastModified: fileInfo.ModTime(),
+			ContentHash:  fileInfo.ContentHash,
+			DocumentID:   chunks[0].ID,
+			ChunkIDs:     chunkIDs,
+			LastIndexed:  time.Now(),
+			Links:        fileInfo.Links,
+
+			Transcluded:       fileInfo.Transcluded,
+			TransclusionDepth: idx.transclusionDepth,
+			NormalizerVersion: markdown.Version,
+			ChunkSettings:     fileInfo.ChunkSettings,
+		},
+		isNew: !exists,
+	})
+
+	return true
+}
+
+// uploadBatch upserts the new and changed chunks of a batch, which embeds them, and updates the
+// metadata of its unchanged chunks under their stored embeddings. It returns the number of chunks
+// that were embedded.
+func (idx *ObsidianIndexer) uploadBatch(ctx context.Context, batch uploadBatch) (int, error) {
+	if len(batch.documents) > 0 {
+		if err := idx.client.UpsertDocuments(ctx, batch.documents); err != nil {
+			return 0, err
+		}
+	}
+	if len(batch.kept) == 0 {
+		return len(batch.documents), nil
+	}
+
+	moves := make([]chroma.DocumentMove, len(batch.kept))
+	for i, chunk := range batch.kept {
+		moves[i] = chroma.DocumentMove{OldID: chunk.ID, Document: chunk}
+	}
+	if err := idx.client.MoveDocuments(ctx, moves); err != nil {
+		// The stored chunks may be missing, e.g. after the collection was cleared: embed them again
+		log.Printf("Failed to update metadata of %d unchanged chunks, embedding them again: %v", len(batch.kept), err)
+		if err := idx.client.UpsertDocuments(ctx, batch.kept); err != nil {
+			return 0, err
+		}
+		return len(batch.documents) + len(batch.kept), nil
+	}
+
+	return len(batch.documents), nil
+}
+
+// splitKeptChunks separates the chunks whose ID, and therefore text, is among the chunk IDs of the
+// previous version of a file from the chunks that need to be embedded
+func splitKeptChunks(chunks []chroma.Document, previousIDs []string) (changed, kept []chroma.Document) {
+	previous := make(map[string]bool, len(previousIDs))
+	for _, id := range previousIDs {
+		previous[id] = true
+	}
+
+	for _, chunk := range chunks {
+		if previous[chunk.ID] {
+			kept = append(kept, chunk)
+		} else {
+			changed = append(changed, chunk)
+		}
+	}
+
+	return changed, kept
+}
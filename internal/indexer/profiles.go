@@ -0,0 +1,225 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"obsidian-ai-agent/internal/markdown"
+)
+
+// Enrichment chooses the context that is embedded together with the text of a note's chunks
+type Enrichment string
+
+const (
+	// EnrichFrontmatter embeds the frontmatter (summary, tags, categories) as text before the body
+	EnrichFrontmatter Enrichment = "frontmatter"
+	// EnrichNone embeds the body only; the frontmatter is still stored as chunk metadata
+	EnrichNone Enrichment = "none"
+	// EnrichHeadings embeds the frontmatter and starts the chunks that continue a long section with
+	// the section's heading breadcrumb
+	EnrichHeadings Enrichment = "headings"
+)
+
+// ParseEnrichment parses an enrichment name; an empty name selects EnrichFrontmatter
+func ParseEnrichment(name string) (Enrichment, error) {
+	switch enrichment := Enrichment(strings.ToLower(strings.TrimSpace(name))); enrichment {
+	case "":
+		return EnrichFrontmatter, nil
+	case EnrichFrontmatter, EnrichNone, EnrichHeadings:
+		return enrichment, nil
+	default:
+		return "", fmt.Errorf("unknown enrichment %q (expected %s, %s or %s)", name, EnrichFrontmatter, EnrichNone, EnrichHeadings)
+	}
+}
+
+// ChunkProfile overrides the chunking settings of the notes it matches. A note matches when its
+// path matches one of Paths and it has one of Tags; an empty list matches every note. Settings
+// left at their zero value are taken from the indexer.
+type ChunkProfile struct {
+	// Name is stored as "chunk_profile" metadata on the chunks of matching notes
+	Name string `yaml:"name"`
+	// Paths are glob patterns relative to the vault root, e.g. "Daily/**"; patterns starting
+	// with "!" exclude notes
+	Paths []string `yaml:"paths"`
+	// Tags select notes with any of these tags; "meeting" also matches "meeting/weekly"
+	Tags          []string      `yaml:"tags"`
+	ChunkStrategy ChunkStrategy `yaml:"strategy"`
+	// OverlapSentences is used with the profile's ChunkStrategy
+	OverlapSentences int `yaml:"overlap_sentences"`
+	// ChunkSize and ChunkOverlap are in the unit of the indexer's chunk size (tokens when chunks
+	// are sized in tokens); ChunkOverlap is used with the profile's ChunkSize
+	ChunkSize    int        `yaml:"chunk_size"`
+	ChunkOverlap int        `yaml:"chunk_overlap"`
+	Enrichment   Enrichment `yaml:"enrichment"`
+}
+
+// LoadChunkProfiles reads chunking profiles from a YAML file with a "profiles" list, e.g.
+//
+//	profiles:
+//	  - name: daily
+//	    paths: ["Daily/**"]
+//	    strategy: note
+func LoadChunkProfiles(path string) ([]ChunkProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk profiles: %w", err)
+	}
+
+	var file struct {
+		Profiles []ChunkProfile `yaml:"profiles"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse chunk profiles %s: %w", path, err)
+	}
+
+	for i, profile := range file.Profiles {
+		if strings.TrimSpace(profile.Name) == "" {
+			return nil, fmt.Errorf("chunk profile %d in %s has no name", i+1, path)
+		}
+		if profile.ChunkStrategy != "" {
+			if file.Profiles[i].ChunkStrategy, err = ParseChunkStrategy(string(profile.ChunkStrategy)); err != nil {
+				return nil, fmt.Errorf("chunk profile %q: %w", profile.Name, err)
+			}
+		}
+		if profile.Enrichment != "" {
+			if file.Profiles[i].Enrichment, err = ParseEnrichment(string(profile.Enrichment)); err != nil {
+				return nil, fmt.Errorf("chunk profile %q: %w", profile.Name, err)
+			}
+		}
+	}
+
+	return file.Profiles, nil
+}
+
+// chunkProfile is a ChunkProfile with its patterns compiled and its tags normalised
+type chunkProfile struct {
+	ChunkProfile
+	paths *pathFilter
+	tags  []string
+}
+
+// compileProfiles prepares chunking profiles for matching notes
+func compileProfiles(profiles []ChunkProfile) []chunkProfile {
+	compiled := make([]chunkProfile, len(profiles))
+	for i, profile := range profiles {
+		compiled[i] = chunkProfile{
+			ChunkProfile: profile,
+			paths:        newPathFilter(profile.Paths, nil),
+			tags:         normalizeTags(profile.Tags),
+		}
+	}
+	return compiled
+}
+
+// matches reports whether the profile selects a note by its vault-relative path and its tags,
+// including the parents of nested tags
+func (p chunkProfile) matches(relPath string, tags []string) bool {
+	if !p.paths.matches(relPath) {
+		return false
+	}
+	if len(p.tags) == 0 {
+		return true
+	}
+
+	for _, tag := range tags {
+		for _, wanted := range p.tags {
+			if tag == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// chunkSettings are the chunking settings of a note
+type chunkSettings struct {
+	profile          string // Name of the matched profile, empty for the indexer's settings
+	strategy         ChunkStrategy
+	size             int
+	overlap          int
+	overlapSentences int
+	enrichment       Enrichment
+}
+
+// fingerprint identifies the settings, including the unit chunks are sized in, so notes are
+// chunked again when the settings that apply to them change
+func (s chunkSettings) fingerprint(idx *ObsidianIndexer) string {
+	unit := "characters"
+	if idx.tokenSizing {
+		unit = "tokens"
+	}
+	fingerprint := fmt.Sprintf("%s|%s|%d|%d|%d|%s|%s", s.profile, s.strategy, s.size, s.overlap, s.overlapSentences, s.enrichment, unit)
+	if s.strategy == ChunkBySemantic {
+		fingerprint += fmt.Sprintf("|%g|%d|%d", idx.semanticPercentile, idx.semanticWindow, idx.semanticMinSize)
+	}
+
+	sum := sha256.Sum256([]byte(fingerprint))
+	return hex.EncodeToString(sum[:8])
+}
+
+// defaultChunkSettings returns the indexer's chunking settings
+func (idx *ObsidianIndexer) defaultChunkSettings() chunkSettings {
+	return chunkSettings{
+		strategy:         idx.chunkStrategy,
+		size:             idx.chunkSize,
+		overlap:          idx.chunkOverlap,
+		overlapSentences: idx.overlapSentences,
+		enrichment:       idx.enrichment,
+	}
+}
+
+// chunkSettingsFor returns the chunking settings of a note: those of the first profile matching
+// its path and tags, or the indexer's settings
+func (idx *ObsidianIndexer) chunkSettingsFor(filePath string, tags []string) chunkSettings {
+	settings := idx.defaultChunkSettings()
+	if len(idx.profiles) == 0 {
+		return settings
+	}
+
+	relPath, err := vaultRelativePath(idx.vaultPath, filePath)
+	if err != nil {
+		return settings
+	}
+	tags = expandNestedTags(tags)
+
+	for _, profile := range idx.profiles {
+		if !profile.matches(relPath, tags) {
+			continue
+		}
+
+		settings.profile = profile.Name
+		if profile.ChunkStrategy != "" {
+			settings.strategy = profile.ChunkStrategy
+			settings.overlapSentences = profile.OverlapSentences
+		}
+		if profile.ChunkSize > 0 {
+			settings.size, settings.overlap = profile.ChunkSize, profile.ChunkOverlap
+			if idx.tokenSizing {
+				settings.size = min(settings.size, idx.tokenizer.MaxTokens())
+			}
+		}
+		if profile.Enrichment != "" {
+			settings.enrichment = profile.Enrichment
+		}
+		break
+	}
+
+	return settings
+}
+
+// noteChunkSettings returns the chunking settings of a note from its content, matching profiles
+// against its tags the way processFileWithChunks does
+func (idx *ObsidianIndexer) noteChunkSettings(filePath, content string) chunkSettings {
+	if len(idx.profiles) == 0 {
+		return idx.defaultChunkSettings()
+	}
+
+	_, metadata := idx.enhanceContentWithFrontmatter(markdown.StripComments(content), filePath)
+	tags, _ := metadata["tags"].([]string)
+	return idx.chunkSettingsFor(filePath, tags)
+}
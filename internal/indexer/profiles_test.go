@@ -0,0 +1,179 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProfiles are profiles for daily, meeting, literature and plain notes
+var testProfiles = []ChunkProfile{
+	{Name: "daily", Paths: []string{"Daily/**"}, ChunkStrategy: ChunkByNote},
+	{Name: "meetings", Tags: []string{"#Meeting"}, ChunkSize: 400, ChunkOverlap: 40},
+	{Name: "literature", Paths: []string{"Literature/**"}, ChunkSize: 80, Enrichment: EnrichHeadings},
+	{Name: "plain", Paths: []string{"Plain/**"}, Enrichment: EnrichNone},
+}
+
+// TestLoadChunkProfiles tests reading and validating profiles from YAML
+func TestLoadChunkProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "profiles.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`profiles:
+  - name: daily
+    paths: ["Daily/**"]
+    strategy: Note
+  - name: literature
+    paths: ["Literature/**", "!Literature/Archive/**"]
+    tags: [book]
+    strategy: sentences
+    overlap_sentences: 2
+    chunk_size: 120
+    chunk_overlap: 30
+    enrichment: headings
+`), 0644))
+
+	profiles, err := LoadChunkProfiles(path)
+	require.NoError(t, err)
+	require.Len(t, profiles, 2)
+	assert.Equal(t, ChunkProfile{Name: "daily", Paths: []string{"Daily/**"}, ChunkStrategy: ChunkByNote}, profiles[0])
+	assert.Equal(t, ChunkProfile{
+		Name:             "literature",
+		Paths:            []string{"Literature/**", "!Literature/Archive/**"},
+		Tags:             []string{"book"},
+		ChunkStrategy:    ChunkBySentences,
+		OverlapSentences: 2,
+		ChunkSize:        120,
+		ChunkOverlap:     30,
+		Enrichment:       EnrichHeadings,
+	}, profiles[1])
+
+	invalid := map[string]string{
+		"unnamed":            "profiles:\n  - paths: [\"Daily/**\"]\n",
+		"unknown strategy":   "profiles:\n  - name: daily\n    strategy: words\n",
+		"unknown enrichment": "profiles:\n  - name: daily\n    enrichment: everything\n",
+		"invalid yaml":       "profiles: [",
+	}
+	for name, content := range invalid {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		_, err := LoadChunkProfiles(path)
+		assert.Error(t, err, name)
+	}
+}
+
+// TestChunkSettingsFor tests selecting the first profile matching a note's path or tags
+func TestChunkSettingsFor(t *testing.T) {
+	vault := t.TempDir()
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath:        vault,
+		ChunkSize:        2000,
+		ChunkOverlap:     200,
+		ChunkStrategy:    ChunkByParagraphs,
+		OverlapSentences: 1,
+		Profiles:         testProfiles,
+	})
+
+	settings := indexer.chunkSettingsFor(filepath.Join(vault, "Daily", "2025-01-01.md"), nil)
+	assert.Equal(t, chunkSettings{profile: "daily", strategy: ChunkByNote, size: 2000, overlap: 200}, settings)
+
+	settings = indexer.chunkSettingsFor(filepath.Join(vault, "Work", "standup.md"), []string{"meeting/weekly"})
+	assert.Equal(t, chunkSettings{profile: "meetings", strategy: ChunkByParagraphs, size: 400, overlap: 40, overlapSentences: 1}, settings, "nested tags match their parent")
+
+	settings = indexer.chunkSettingsFor(filepath.Join(vault, "Literature", "book.md"), []string{"meeting"})
+	assert.Equal(t, "meetings", settings.profile, "the first matching profile applies")
+
+	settings = indexer.chunkSettingsFor(filepath.Join(vault, "Literature", "book.md"), nil)
+	assert.Equal(t, chunkSettings{profile: "literature", strategy: ChunkByParagraphs, size: 80, overlapSentences: 1, enrichment: EnrichHeadings}, settings)
+
+	settings = indexer.chunkSettingsFor(filepath.Join(vault, "Projects", "plan.md"), []string{"project"})
+	assert.Equal(t, indexer.defaultChunkSettings(), settings, "notes without a profile use the indexer's settings")
+}
+
+// TestChunkProfilesInProcessFile tests that profiles change how notes are chunked and enriched
+func TestChunkProfilesInProcessFile(t *testing.T) {
+	vault := t.TempDir()
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{VaultPath: vault, ChunkSize: 2000, Profiles: testProfiles})
+
+	write := func(relPath, content string) string {
+		path := filepath.Join(vault, filepath.FromSlash(relPath))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	// Daily notes are embedded whole
+	daily := write("Daily/2025-01-01.md", "# Morning\n\nWoke up early.\n\n# Evening\n\nRead a book.")
+	chunks, _, err := indexer.processFileWithChunks(daily)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "This document covers Daily topics. # Morning Woke up early. # Evening Read a book.", chunks[0].Content)
+	assert.Equal(t, "note", chunks[0].Metadata["chunk_type"])
+	assert.Equal(t, "daily", chunks[0].Metadata["chunk_profile"])
+
+	// Continuations of long literature sections start with their breadcrumb and still fit
+	book := write("Literature/book.md", "# Book\n\n## Chapter One\n\n"+
+		"The first paragraph is about the opening scene.\n\n"+
+		"The second paragraph is about the turning point.\n\n"+
+		"The third paragraph is about the ending.")
+	chunks, _, err = indexer.processFileWithChunks(book)
+	require.NoError(t, err)
+	require.Greater(t, len(chunks), 2)
+	assert.Equal(t, "This document covers Literature topics. # Book ## Chapter One", chunks[0].Content)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk.Content), 80, chunk.Content)
+		assert.Equal(t, "literature", chunk.Metadata["chunk_profile"])
+	}
+	assert.Equal(t, "Book > Chapter One The first paragraph is about the opening scene.", chunks[1].Content)
+
+	// Plain notes embed their body without the frontmatter, which is still metadata
+	plain := write("Plain/recipe.md", "---\ntags: [cooking]\n---\n# Soup\n\nBoil the water first.")
+	chunks, _, err = indexer.processFileWithChunks(plain)
+	require.NoError(t, err)
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "# Soup Boil the water first.", chunks[0].Content)
+	assert.Equal(t, "cooking", chunks[0].Metadata["tags"])
+
+	// Notes without a profile have no chunk_profile metadata
+	other := write("Other/note.md", "---\ntags: [cooking]\n---\n# Soup\n\nBoil the water first.")
+	chunks, _, err = indexer.processFileWithChunks(other)
+	require.NoError(t, err)
+	require.NotEmpty(t, chunks)
+	assert.Contains(t, chunks[0].Content, "Tags: cooking.")
+	assert.NotContains(t, chunks[0].Metadata, "chunk_profile")
+}
+
+// TestChangedChunkSettingsReindexNotes tests that notes are chunked again when the settings that
+// apply to them change, and only then
+func TestChangedChunkSettingsReindexNotes(t *testing.T) {
+	vault := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(vault, "Daily"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(vault, "Daily", "today.md"), []byte("# Today\n\nWoke up early."), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(vault, "plan.md"), []byte("# Plan\n\nShip the release."), 0644))
+
+	run := func(config Config) *IndexResult {
+		config.VaultPath, config.BatchSize, config.ChunkSize = vault, 10, 2000
+		result, err := NewObsidianIndexer(NewMockChromaClient(), &config).ReindexVault(context.Background(), nil)
+		require.NoError(t, err)
+		return result
+	}
+
+	result := run(Config{Profiles: testProfiles})
+	assert.Equal(t, 2, result.IndexedFiles)
+
+	result = run(Config{Profiles: testProfiles})
+	assert.Equal(t, 2, result.SkippedFiles, "unchanged settings")
+
+	daily := []ChunkProfile{{Name: "daily", Paths: []string{"Daily/**"}, ChunkStrategy: ChunkBySentences}}
+	result = run(Config{Profiles: daily})
+	assert.Equal(t, 1, result.UpdatedFiles, "only the notes of the changed profile")
+	assert.Equal(t, 1, result.SkippedFiles)
+
+	result = run(Config{Profiles: daily, Enrichment: EnrichNone})
+	assert.Equal(t, 2, result.UpdatedFiles, "a changed default applies to every note")
+
+	result = run(Config{Profiles: daily, Enrichment: EnrichNone, ChunkStrategy: ChunkBySentences})
+	assert.Equal(t, 1, result.UpdatedFiles, "the profile's strategy still applies to daily notes")
+}
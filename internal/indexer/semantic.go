@@ -0,0 +1,156 @@
+package indexer
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Embedder embeds texts with the embedding function of the collection, e.g. *chroma.Client
+type Embedder interface {
+	EmbedTexts(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+const (
+	// defaultSemanticPercentile is the percentile of neighbour similarities below which a note is cut
+	defaultSemanticPercentile = 10
+	// defaultSemanticWindow is the number of sentences compared on either side of a possible cut
+	defaultSemanticWindow = 2
+)
+
+// packSemantic cleans markdown blocks, embeds their sentences and cuts chunks where the topic
+// shifts: where the similarity between the windows of sentences before and after a gap is in the
+// lowest semanticPercentile percent of the section's gaps. Chunks are never cut at a topic shift
+// below semanticMinSize and never grow beyond chunkSize. Without an embedder, or if embedding
+// fails, sentences are packed by size. Sentences larger than chunkSize are split by size with overlap.
+func (idx *ObsidianIndexer) packSemantic(blocks []string, chunkSize, overlap int) []string {
+	var sentences []string
+	for _, block := range blocks {
+		if cleaned := idx.cleanContent(block); cleaned != "" {
+			sentences = append(sentences, splitSentences(cleaned)...)
+		}
+	}
+	if idx.embedder == nil || len(sentences) < 3 {
+		return idx.packSentences(blocks, chunkSize, overlap, 0)
+	}
+
+	// The indexer reads files without a context; embedding is bounded by the embedding function
+	vectors, err := idx.embedder.EmbedTexts(context.Background(), sentences)
+	if err != nil || len(vectors) != len(sentences) {
+		log.Printf("Warning: failed to embed sentences for semantic chunking, splitting by sentences: %v", err)
+		return idx.packSentences(blocks, chunkSize, overlap, 0)
+	}
+
+	window, cutPercentile, minSize := idx.semanticWindow, idx.semanticPercentile, idx.semanticMinSize
+	if window <= 0 {
+		window = defaultSemanticWindow
+	}
+	if cutPercentile <= 0 {
+		cutPercentile = defaultSemanticPercentile
+	}
+	if minSize <= 0 {
+		minSize = chunkSize / 4
+	}
+
+	similarities := windowSimilarities(vectors, window)
+	threshold := percentile(similarities, cutPercentile)
+
+	var chunks []string
+	var current []string
+	size := 0
+	separator := idx.separatorSize()
+
+	emit := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, " "))
+		}
+		current, size = nil, 0
+	}
+
+	for i, sentence := range sentences {
+		sentenceSize := idx.textSize(sentence)
+		if sentenceSize > chunkSize {
+			emit()
+			chunks = append(chunks, idx.splitBySize(sentence, chunkSize, overlap)...)
+			continue
+		}
+
+		if len(current) > 0 && size+separator+sentenceSize > chunkSize {
+			emit()
+		}
+		if len(current) > 0 {
+			size += separator
+		}
+		current = append(current, sentence)
+		size += sentenceSize
+
+		// Cut at a topic shift once the chunk is large enough
+		if i < len(similarities) && similarities[i] <= threshold && size >= minSize {
+			emit()
+		}
+	}
+	emit()
+
+	return chunks
+}
+
+// windowSimilarities returns for every gap between two sentences the cosine similarity of the mean
+// vectors of up to window sentences before and after it
+func windowSimilarities(vectors [][]float32, window int) []float64 {
+	similarities := make([]float64, 0, max(len(vectors)-1, 0))
+	for gap := 0; gap+1 < len(vectors); gap++ {
+		before := meanVector(vectors[max(0, gap+1-window) : gap+1])
+		after := meanVector(vectors[gap+1 : min(len(vectors), gap+1+window)])
+		similarities = append(similarities, cosineSimilarity(before, after))
+	}
+	return similarities
+}
+
+// meanVector returns the element-wise mean of vectors
+func meanVector(vectors [][]float32) []float64 {
+	if len(vectors) == 0 {
+		return nil
+	}
+	mean := make([]float64, len(vectors[0]))
+	for _, vector := range vectors {
+		for i := range mean {
+			if i < len(vector) {
+				mean[i] += float64(vector[i])
+			}
+		}
+	}
+	for i := range mean {
+		mean[i] /= float64(len(vectors))
+	}
+	return mean
+}
+
+// cosineSimilarity returns the cosine similarity of a and b, or 0 if either is a zero vector
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := 0; i < len(a) && i < len(b); i++ {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// percentile returns the p-th percentile (0-100) of values, interpolating between neighbours
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := math.Min(math.Max(p, 0), 100) / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
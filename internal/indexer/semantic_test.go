@@ -0,0 +1,129 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// topicEmbedder embeds every text as a unit vector of the first topic keyword it contains
+type topicEmbedder struct {
+	topics []string
+	calls  int
+	err    error
+}
+
+func (e *topicEmbedder) EmbedTexts(ctx context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(e.topics))
+		for j, topic := range e.topics {
+			if strings.Contains(text, topic) {
+				vectors[i][j] = 1
+				break
+			}
+		}
+	}
+	return vectors, nil
+}
+
+const topicShiftText = "Cats purr softly. Cats sleep all day long. Cats chase small mice. " +
+	"Rockets burn fuel fast. Rockets reach orbit. Rockets need engineers."
+
+// TestPackSemantic tests cutting chunks at topic shifts within the min and max size guards
+func TestPackSemantic(t *testing.T) {
+	embedder := &topicEmbedder{topics: []string{"Cats", "Rockets"}}
+	indexer := &ObsidianIndexer{embedder: embedder}
+
+	chunks := indexer.packSemantic([]string{topicShiftText}, 200, 0)
+	assert.Equal(t, []string{
+		"Cats purr softly. Cats sleep all day long. Cats chase small mice.",
+		"Rockets burn fuel fast. Rockets reach orbit. Rockets need engineers.",
+	}, chunks, "the note is cut where the topic shifts")
+	assert.Equal(t, 1, embedder.calls, "all sentences are embedded in a single request")
+
+	indexer.semanticMinSize = 100
+	chunks = indexer.packSemantic([]string{topicShiftText}, 200, 0)
+	assert.Equal(t, []string{topicShiftText}, chunks, "chunks smaller than the minimum size are not cut")
+
+	indexer.semanticMinSize = 0
+	chunks = indexer.packSemantic([]string{topicShiftText}, 50, 0)
+	assert.Equal(t, []string{
+		"Cats purr softly. Cats sleep all day long.",
+		"Cats chase small mice.",
+		"Rockets burn fuel fast. Rockets reach orbit.",
+		"Rockets need engineers.",
+	}, chunks, "chunks never grow beyond the chunk size")
+}
+
+// TestPackSemanticFallback tests packing sentences by size without a working embedder
+func TestPackSemanticFallback(t *testing.T) {
+	indexer := &ObsidianIndexer{}
+	expected := indexer.packSentences([]string{topicShiftText}, 50, 0, 0)
+	assert.Equal(t, expected, indexer.packSemantic([]string{topicShiftText}, 50, 0), "without an embedder")
+
+	embedder := &topicEmbedder{err: errors.New("embedding function unavailable")}
+	indexer.embedder = embedder
+	assert.Equal(t, expected, indexer.packSemantic([]string{topicShiftText}, 50, 0), "when embedding fails")
+	assert.Equal(t, 1, embedder.calls)
+
+	embedder.calls = 0
+	assert.Equal(t, []string{"Too short. To embed."}, indexer.packSemantic([]string{"Too short. To embed."}, 50, 0))
+	assert.Zero(t, embedder.calls, "fewer than three sentences are not embedded")
+}
+
+// TestChunkBySemanticStrategy tests selecting the semantic strategy in the config
+func TestChunkBySemanticStrategy(t *testing.T) {
+	strategy, err := ParseChunkStrategy("semantic")
+	require.NoError(t, err)
+	assert.Equal(t, ChunkBySemantic, strategy)
+
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath:     t.TempDir(),
+		ChunkSize:     100,
+		ChunkStrategy: ChunkBySemantic,
+		Embedder:      &topicEmbedder{topics: []string{"Cats", "Rockets"}},
+	})
+
+	chunks := indexer.chunkContent("# Topics\n\n"+topicShiftText, "/vault/topics.md")
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "# Topics Cats purr softly. Cats sleep all day long. Cats chase small mice.", chunks[0].Content)
+	assert.Equal(t, "Rockets burn fuel fast. Rockets reach orbit. Rockets need engineers.", chunks[1].Content)
+}
+
+// TestWindowSimilarities tests the similarity of neighbouring sentence windows
+func TestWindowSimilarities(t *testing.T) {
+	vectors := [][]float32{{1, 0}, {1, 0}, {0, 1}, {0, 1}}
+
+	similarities := windowSimilarities(vectors, 1)
+	require.Len(t, similarities, 3)
+	assert.InDelta(t, 1, similarities[0], 1e-9)
+	assert.InDelta(t, 0, similarities[1], 1e-9)
+	assert.InDelta(t, 1, similarities[2], 1e-9)
+
+	similarities = windowSimilarities(vectors, 2)
+	assert.InDelta(t, 0.7071, similarities[0], 1e-4, "the window after the first gap already leans to the second topic")
+	assert.InDelta(t, 0, similarities[1], 1e-9)
+
+	assert.Empty(t, windowSimilarities(vectors[:1], 2))
+	assert.InDelta(t, 0, cosineSimilarity([]float64{0, 0}, []float64{1, 0}), 1e-9, "zero vectors are dissimilar")
+}
+
+// TestPercentile tests the interpolated percentile of the similarities
+func TestPercentile(t *testing.T) {
+	values := []float64{3, 1, 2, 4}
+	assert.InDelta(t, 1, percentile(values, 0), 1e-9)
+	assert.InDelta(t, 2.5, percentile(values, 50), 1e-9)
+	assert.InDelta(t, 4, percentile(values, 100), 1e-9)
+	assert.InDelta(t, 1.3, percentile(values, 10), 1e-9)
+	assert.Equal(t, []float64{3, 1, 2, 4}, values, "values are not reordered")
+	assert.Zero(t, percentile(nil, 10))
+}
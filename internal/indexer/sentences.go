@@ -0,0 +1,202 @@
+package indexer
+
+import (
+	"strings"
+	"unicode"
+)
+
+// abbreviations are English, Dutch and French abbreviations (lowercase, without the final period)
+// whose period does not end a sentence. Text is transliterated before it is split, so accented
+// forms are listed without their accents. Abbreviations that are also ordinary words are left out,
+// so "Give it to me." still ends a sentence.
+var abbreviations = map[string]bool{
+	// English
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true, "st": true,
+	"vs": true, "etc": true, "e.g": true, "i.e": true, "cf": true, "fig": true, "vol": true,
+	"approx": true, "dept": true, "inc": true, "ltd": true, "corp": true,
+	"jan": true, "feb": true, "mar": true, "apr": true, "jun": true, "jul": true, "aug": true,
+	"sep": true, "sept": true, "oct": true, "nov": true, "dec": true, "p": true, "pp": true,
+	"ch": true, "eds": true, "u.s": true, "a.m": true, "p.m": true,
+
+	// Dutch
+	"bijv": true, "bv": true, "b.v": true, "d.w.z": true, "dwz": true, "o.a": true, "m.a.w": true,
+	"enz": true, "evt": true, "mevr": true, "dhr": true, "drs": true, "ir": true,
+	"nl": true, "ca": true, "zgn": true, "t.a.v": true, "incl": true, "excl": true, "resp": true,
+	"vgl": true, "blz": true, "nr": true, "jl": true, "a.s": true, "m.b.t": true, "i.p.v": true,
+	"z.s.m": true, "t.z.t": true, "o.b.v": true,
+
+	// French
+	"m": true, "mme": true, "mlle": true, "mm": true, "pr": true, "p.ex": true,
+	"c.-a-d": true, "c.-à-d": true, "j.-c": true, "chap": true,
+	"n.b": true, "cie": true, "boul": true, "bd": true,
+}
+
+// numberAbbreviations are abbreviations that are also ordinary words; their period only does not
+// end a sentence when a number follows, as in "No. 5"
+var numberAbbreviations = map[string]bool{"no": true}
+
+// sentenceClosers are characters that may follow a sentence's final punctuation, e.g. `."` or `?)`
+const sentenceClosers = ".!?\"')]"
+
+// splitSentences splits cleaned text into sentences. A sentence ends at ".", "!" or "?" followed
+// by a space and a word that does not start with a lowercase letter; periods of abbreviations and
+// initials (as in "W. Chan Kim") do not end a sentence.
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '.' && c != '!' && c != '?' {
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) && strings.IndexByte(sentenceClosers, text[end]) >= 0 {
+			end++
+		}
+		if end >= len(text) {
+			break
+		}
+		if text[end] != ' ' {
+			i = end - 1 // Inside a word or number, e.g. "example.com" or "3.14"
+			continue
+		}
+
+		next := end
+		for next < len(text) && text[next] == ' ' {
+			next++
+		}
+		if next == len(text) {
+			break
+		}
+
+		r := rune(text[next])
+		if unicode.IsLower(r) || (c == '.' && isAbbreviation(text[start:i], text[next:])) {
+			i = end - 1
+			continue
+		}
+
+		sentences = append(sentences, strings.TrimSpace(text[start:end]))
+		start = next
+		i = next - 1
+	}
+
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+
+	return sentences
+}
+
+// isAbbreviation reports whether the last word of text, which is followed by a period and then by
+// next, is an abbreviation or an initial
+func isAbbreviation(text, next string) bool {
+	word := text[strings.LastIndexByte(text, ' ')+1:]
+	word = strings.TrimLeft(word, "(\"'[")
+
+	if isInitial(word, next) {
+		return true
+	}
+	word = strings.ToLower(word)
+	if numberAbbreviations[word] {
+		return next != "" && unicode.IsDigit(rune(next[0]))
+	}
+	return abbreviations[word]
+}
+
+// isInitial reports whether word is the initial of a name, as in "W. Chan Kim": an uppercase
+// letter other than "I" followed by a capitalised word. Ordinary one-letter words such as
+// "I", "u" (Dutch) and "a" (French) end sentences.
+func isInitial(word, next string) bool {
+	letters := []rune(word)
+	if len(letters) != 1 || !unicode.IsUpper(letters[0]) || word == "I" || next == "" {
+		return false
+	}
+	return unicode.IsUpper([]rune(next)[0])
+}
+
+// packSentences cleans markdown blocks and packs their sentences into chunks of up to chunkSize.
+// A paragraph that fits in a chunk is never split: it starts a new chunk when the current one is
+// too full. The next chunk repeats the last overlapSentences sentences of the previous one.
+// Sentences larger than chunkSize are split by size with overlap.
+func (idx *ObsidianIndexer) packSentences(blocks []string, chunkSize, overlap, overlapSentences int) []string {
+	var chunks []string
+	var current []string
+	var sizes []int       // textSize of the sentences in current
+	size, pending := 0, 0 // Size of current joined; number of sentences not in a chunk yet
+	separator := idx.separatorSize()
+
+	// dropCarried removes carried over sentences until text of size next fits after them
+	dropCarried := func(next int) {
+		for len(current) > 0 && size+separator+next > chunkSize {
+			size -= sizes[0] + separator
+			current, sizes = current[1:], sizes[1:]
+		}
+		if len(current) == 0 {
+			size = 0
+		}
+	}
+
+	emit := func() {
+		if pending == 0 {
+			return
+		}
+		chunks = append(chunks, strings.Join(current, " "))
+		pending = 0
+
+		// Carry over trailing sentences, but never the whole chunk
+		carried := min(overlapSentences, len(current)-1)
+		current = append([]string(nil), current[len(current)-carried:]...)
+		sizes = append([]int(nil), sizes[len(sizes)-carried:]...)
+		size = 0
+		for k, s := range sizes {
+			if k > 0 {
+				size += separator
+			}
+			size += s
+		}
+	}
+
+	for _, block := range blocks {
+		cleaned := idx.cleanContent(block)
+		if cleaned == "" {
+			continue
+		}
+
+		// Keep a paragraph that fits in a chunk together
+		if blockSize := idx.textSize(cleaned); blockSize <= chunkSize && len(current) > 0 && size+separator+blockSize > chunkSize {
+			emit()
+			dropCarried(blockSize)
+		}
+
+		for _, sentence := range splitSentences(cleaned) {
+			sentenceSize := idx.textSize(sentence)
+
+			if sentenceSize > chunkSize {
+				// Sentences waiting for a chunk, such as the section heading, lead the oversized sentence
+				if pending > 0 {
+					sentence = strings.Join(append(current, sentence), " ")
+				}
+				current, sizes, size, pending = nil, nil, 0, 0
+				chunks = append(chunks, idx.splitBySize(sentence, chunkSize, overlap)...)
+				continue
+			}
+
+			if len(current) > 0 && size+separator+sentenceSize > chunkSize {
+				emit()
+				dropCarried(sentenceSize)
+			}
+			if len(current) > 0 {
+				size += separator
+			}
+			current = append(current, sentence)
+			sizes = append(sizes, sentenceSize)
+			size += sentenceSize
+			pending++
+		}
+	}
+	emit()
+
+	return chunks
+}
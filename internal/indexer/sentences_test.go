@@ -0,0 +1,93 @@
+package indexer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSplitSentences tests sentence detection with English, Dutch and French abbreviations
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"simple", "First one. Second one! Third one? Fourth", []string{"First one.", "Second one!", "Third one?", "Fourth"}},
+		{"english abbreviations", "Ask Dr. Smith, e.g. about the plan. Then stop.", []string{"Ask Dr. Smith, e.g. about the plan.", "Then stop."}},
+		{"initials", "Blue Ocean Strategy by W. Chan Kim. Great read.", []string{"Blue Ocean Strategy by W. Chan Kim.", "Great read."}},
+		{"dutch abbreviations", "Neem bijv. Jan mee. Dhr. Jansen komt d.w.z. Morgen. Klaar.", []string{"Neem bijv. Jan mee.", "Dhr. Jansen komt d.w.z. Morgen.", "Klaar."}},
+		{"french abbreviations", "Voir M. Dupont et Mme. Durand. C'est fini.", []string{"Voir M. Dupont et Mme. Durand.", "C'est fini."}},
+		{"ordinary words", "Give it to me. Then we left. The answer is no. We stop.", []string{"Give it to me.", "Then we left.", "The answer is no.", "We stop."}},
+		{"one-letter words", "He is taller than I. We left. Dank u. Tot ziens. Il y a. Puis rien.", []string{"He is taller than I.", "We left.", "Dank u.", "Tot ziens.", "Il y a.", "Puis rien."}},
+		{"numbered", "See No. 5 first. Then the rest.", []string{"See No. 5 first.", "Then the rest."}},
+		{"lowercase continuation", "Wait... then go. Done.", []string{"Wait... then go.", "Done."}},
+		{"closing quotes and brackets", `He said "stop." (Really.) Next.`, []string{`He said "stop."`, "(Really.)", "Next."}},
+		{"numbers and URLs", "Pi is 3.14 on example.com today. Yes.", []string{"Pi is 3.14 on example.com today.", "Yes."}},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, splitSentences(tt.input))
+		})
+	}
+}
+
+// TestPackSentences tests packing paragraphs and sentences with sentence overlap
+func TestPackSentences(t *testing.T) {
+	indexer := &ObsidianIndexer{}
+	blocks := []string{
+		"Alpha one. Alpha two. Alpha three.",
+		"Short para.",
+		"Beta one is here. Beta two is here.",
+	}
+
+	chunks := indexer.packSentences(blocks, 40, 0, 1)
+	assert.Equal(t, []string{
+		"Alpha one. Alpha two. Alpha three.",
+		"Alpha three. Short para.",
+		"Beta one is here. Beta two is here.",
+	}, chunks, "paragraphs that fit are not split and the last sentence is repeated when it fits")
+
+	chunks = indexer.packSentences(blocks, 25, 0, 1)
+	assert.Equal(t, []string{
+		"Alpha one. Alpha two.",
+		"Alpha two. Alpha three.",
+		"Alpha three. Short para.",
+		"Beta one is here.",
+		"Beta two is here.",
+	}, chunks, "long paragraphs are split at sentences and overlap is dropped when it does not fit")
+
+	chunks = indexer.packSentences([]string{"Tiny. " + strings.Repeat("word ", 20) + "end."}, 30, 0, 0)
+	require.Greater(t, len(chunks), 1)
+	assert.True(t, strings.HasPrefix(chunks[0], "Tiny. word"), "a pending sentence leads an oversized one")
+}
+
+// TestChunkBySentencesStrategy tests selecting the sentence strategy in the config
+func TestChunkBySentencesStrategy(t *testing.T) {
+	strategy, err := ParseChunkStrategy("Sentences")
+	require.NoError(t, err)
+	assert.Equal(t, ChunkBySentences, strategy)
+
+	strategy, err = ParseChunkStrategy("")
+	require.NoError(t, err)
+	assert.Equal(t, ChunkByParagraphs, strategy)
+
+	_, err = ParseChunkStrategy("words")
+	assert.Error(t, err)
+
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{VaultPath: t.TempDir(), ChunkSize: 62, ChunkOverlap: 10, ChunkStrategy: ChunkBySentences, OverlapSentences: 1})
+	content := "# Plan\n\nThe first step is small. The second step takes longer. The third step ends it."
+
+	chunks := indexer.chunkContent(content, "/vault/plan.md")
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "# Plan The first step is small. The second step takes longer.", chunks[0].Content)
+	assert.Equal(t, "The second step takes longer. The third step ends it.", chunks[1].Content)
+	for _, chunk := range chunks {
+		assert.Equal(t, "sub_header", chunk.Metadata["chunk_type"])
+		assert.Equal(t, "Plan", chunk.Metadata["heading_path"])
+	}
+}
@@ -0,0 +1,328 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IndexFileName is the name of the index state file stored in the vault root
+const IndexFileName = ".obsidian_index.json"
+
+// StateStore persists the file index between runs. Entries are keyed by slash-separated
+// vault-relative paths so that the state does not depend on how the vault path was given.
+type StateStore interface {
+	// Load returns the stored entries, or an empty map if nothing has been stored yet
+	Load(ctx context.Context) (map[string]FileIndex, error)
+	// Save replaces the stored entries
+	Save(ctx context.Context, files map[string]FileIndex) error
+}
+
+// StateLocker is implemented by state stores that several processes could share. The indexer
+// holds the lock for the whole of each indexing run.
+type StateLocker interface {
+	// Lock takes the store's lock, failing at once if another process holds it, and returns a
+	// function that releases it
+	Lock() (func(), error)
+}
+
+// lockState takes the state store's lock for an indexing run, if the store has one
+func (idx *ObsidianIndexer) lockState() (func(), error) {
+	locker, ok := idx.store.(StateLocker)
+	if !ok {
+		return func() {}, nil
+	}
+	return locker.Lock()
+}
+
+// loadFileIndex loads the file index from the state store and rebuilds the link graph. Runs call
+// it while holding the store's lock, so they start from what other processes last saved.
+func (idx *ObsidianIndexer) loadFileIndex() error {
+	if idx.store == nil {
+		return nil
+	}
+
+	files, err := idx.store.Load(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to load file index: %w", err)
+	}
+
+	// Resolve vault-relative keys against the configured vault path
+	fileMap := make(map[string]FileIndex, len(files))
+	for relPath, entry := range files {
+		path := filepath.Join(idx.vaultPath, filepath.FromSlash(relPath))
+		entry.Path = path
+		fileMap[path] = entry
+	}
+
+	idx.fileIndex = fileMap
+	log.Printf("Loaded file index with %d entries", len(idx.fileIndex))
+
+	idx.rebuildLinkGraph()
+	return nil
+}
+
+// saveFileIndex saves the file index to the state store, keyed by vault-relative paths
+func (idx *ObsidianIndexer) saveFileIndex() error {
+	files := make(map[string]FileIndex, len(idx.fileIndex))
+	for path, entry := range idx.fileIndex {
+		relPath, err := vaultRelativePath(idx.vaultPath, path)
+		if err != nil {
+			log.Printf("Warning: not saving index entry for %s: %v", path, err)
+			continue
+		}
+		entry.Path = relPath
+		files[relPath] = entry
+	}
+
+	// Persist even when the run was cancelled, so committed files are not reindexed
+	return idx.store.Save(context.Background(), files)
+}
+
+// vaultRelativePath converts a file path to a slash-separated path relative to the vault root
+func vaultRelativePath(vaultPath, path string) (string, error) {
+	absVault, err := filepath.Abs(vaultPath)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	relPath, err := filepath.Rel(absVault, absPath)
+	if err != nil {
+		return "", err
+	}
+	if relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path is outside the vault %s", vaultPath)
+	}
+
+	return filepath.ToSlash(relPath), nil
+}
+
+// currentStateVersion is the schema version written by the JSON state store.
+//
+//	1: bare map of file path (as walked, relative to the working directory or absolute) to FileIndex
+//	2: versioned envelope keyed by slash-separated vault-relative paths
+const currentStateVersion = 2
+
+// indexState is the on-disk representation of the file index in the JSON state store
+type indexState struct {
+	Version int                  `json:"version"`
+	Files   map[string]FileIndex `json:"files"`
+}
+
+// stateMigrations upgrade a state from the version it is keyed by to the next version
+var stateMigrations = map[int]func(vaultPath string, state *indexState) error{
+	1: migrateStateV1,
+}
+
+// JSONStateStore keeps the file index in a JSON file, by default inside the vault
+type JSONStateStore struct {
+	path      string
+	vaultPath string // Used to migrate version 1 files keyed by walked paths
+
+	mu     sync.Mutex
+	unlock func() // Releases the lock taken with Lock; nil while it is not held
+}
+
+// NewJSONStateStore creates a JSON state store writing to the given file
+func NewJSONStateStore(path, vaultPath string) *JSONStateStore {
+	return &JSONStateStore{
+		path:      path,
+		vaultPath: vaultPath,
+	}
+}
+
+// Lock takes the lock on the state file until the returned function is called. Load and Save
+// called without it take the lock for their own duration.
+func (s *JSONStateStore) Lock() (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unlock != nil {
+		return nil, fmt.Errorf("file index %s is already locked", s.path)
+	}
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock file index: %w", err)
+	}
+	s.unlock = unlock
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.unlock()
+		s.unlock = nil
+	}, nil
+}
+
+// withLock runs fn while the state file is locked, taking the lock unless it is already held
+func (s *JSONStateStore) withLock(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unlock == nil {
+		unlock, err := lockFile(s.path + ".lock")
+		if err != nil {
+			return fmt.Errorf("failed to lock file index: %w", err)
+		}
+		defer unlock()
+	}
+
+	return fn()
+}
+
+// Load reads the state file, migrating older versions. An unreadable file is moved
+// aside to <path>.corrupt for inspection instead of being silently discarded.
+func (s *JSONStateStore) Load(ctx context.Context) (map[string]FileIndex, error) {
+	var files map[string]FileIndex
+	err := s.withLock(func() error {
+		var err error
+		files, err = s.load()
+		return err
+	})
+	return files, err
+}
+
+// load reads the state file while it is locked
+func (s *JSONStateStore) load() (map[string]FileIndex, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]FileIndex), nil
+		}
+		return nil, err
+	}
+
+	state, err := decodeState(data)
+	if err != nil {
+		corruptFile := s.path + ".corrupt"
+		if renameErr := os.Rename(s.path, corruptFile); renameErr != nil {
+			return nil, fmt.Errorf("failed to parse file index (%v) and to move it aside: %w", err, renameErr)
+		}
+		return nil, fmt.Errorf("failed to parse file index, moved it to %s: %w", corruptFile, err)
+	}
+
+	if state.Version > currentStateVersion {
+		return nil, fmt.Errorf("file index version %d is newer than supported version %d", state.Version, currentStateVersion)
+	}
+
+	for state.Version < currentStateVersion {
+		migrate, ok := stateMigrations[state.Version]
+		if !ok {
+			return nil, fmt.Errorf("no migration for file index version %d", state.Version)
+		}
+		if err := migrate(s.vaultPath, state); err != nil {
+			return nil, fmt.Errorf("failed to migrate file index from version %d: %w", state.Version, err)
+		}
+		log.Printf("Migrated file index to version %d", state.Version)
+	}
+
+	return state.Files, nil
+}
+
+// Save atomically writes the state file while holding the lock
+func (s *JSONStateStore) Save(ctx context.Context, files map[string]FileIndex) error {
+	data, err := json.MarshalIndent(indexState{Version: currentStateVersion, Files: files}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal file index: %w", err)
+	}
+
+	return s.withLock(func() error {
+		if err := writeFileAtomic(s.path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write file index: %w", err)
+		}
+		return nil
+	})
+}
+
+// decodeState parses a state file of any supported version
+func decodeState(data []byte) (*indexState, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	// Version 1 files are a bare map without a version field
+	if _, ok := fields["version"]; !ok {
+		var fileMap map[string]FileIndex
+		if err := json.Unmarshal(data, &fileMap); err != nil {
+			return nil, err
+		}
+		return &indexState{Version: 1, Files: fileMap}, nil
+	}
+
+	var state indexState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.Files == nil {
+		state.Files = make(map[string]FileIndex)
+	}
+
+	return &state, nil
+}
+
+// migrateStateV1 re-keys a version 1 state from walked paths to vault-relative paths
+func migrateStateV1(vaultPath string, state *indexState) error {
+	files := make(map[string]FileIndex, len(state.Files))
+	for path, entry := range state.Files {
+		relPath, err := vaultRelativePath(vaultPath, path)
+		if err != nil {
+			log.Printf("Warning: dropping index entry for %s: %v", path, err)
+			continue
+		}
+		entry.Path = relPath
+		files[relPath] = entry
+	}
+
+	state.Files = files
+	state.Version = 2
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place,
+// so that a crash never leaves a truncated file behind
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	// Remove the temporary file on any failure before the rename
+	success := false
+	defer func() {
+		if !success {
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+
+	success = true
+	return nil
+}
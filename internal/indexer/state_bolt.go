@@ -0,0 +1,122 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltFilesBucket = []byte("files")
+	boltMetaBucket  = []byte("meta")
+	boltVersionKey  = []byte("version")
+)
+
+// BoltStateStore keeps the file index in an embedded key-value database outside the vault,
+// so that syncing the vault does not ship the indexer's bookkeeping around
+type BoltStateStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStateStore opens (or creates) the database at path. The database is locked for
+// the lifetime of the store, so only one process can use it at a time.
+func OpenBoltStateStore(path string) (*BoltStateStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state database %s: %w", path, err)
+	}
+
+	return &BoltStateStore{db: db}, nil
+}
+
+// DefaultBoltStatePath returns a per-vault database path in the user's config directory
+func DefaultBoltStatePath(vaultPath string) (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	absVault, err := filepath.Abs(vaultPath)
+	if err != nil {
+		return "", err
+	}
+
+	vaultHash := sha256.Sum256([]byte(absVault))
+	return filepath.Join(configDir, "obsidian-chroma-sidecar", fmt.Sprintf("%x.db", vaultHash[:8])), nil
+}
+
+// Close releases the database
+func (s *BoltStateStore) Close() error {
+	return s.db.Close()
+}
+
+// Load reads all entries from the database
+func (s *BoltStateStore) Load(ctx context.Context) (map[string]FileIndex, error) {
+	files := make(map[string]FileIndex)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if meta := tx.Bucket(boltMetaBucket); meta != nil {
+			if version := string(meta.Get(boltVersionKey)); version != fmt.Sprint(currentStateVersion) {
+				return fmt.Errorf("unsupported state database version %q", version)
+			}
+		}
+
+		bucket := tx.Bucket(boltFilesBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			var entry FileIndex
+			if err := json.Unmarshal(value, &entry); err != nil {
+				return fmt.Errorf("failed to decode entry %s: %w", key, err)
+			}
+			files[string(key)] = entry
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Save replaces all entries in a single transaction
+func (s *BoltStateStore) Save(ctx context.Context, files map[string]FileIndex) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltFilesBucket); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+
+		bucket, err := tx.CreateBucket(boltFilesBucket)
+		if err != nil {
+			return err
+		}
+
+		for relPath, entry := range files {
+			value, err := json.Marshal(entry)
+			if err != nil {
+				return fmt.Errorf("failed to encode entry %s: %w", relPath, err)
+			}
+			if err := bucket.Put([]byte(relPath), value); err != nil {
+				return err
+			}
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+		if err != nil {
+			return err
+		}
+		return meta.Put(boltVersionKey, []byte(fmt.Sprint(currentStateVersion)))
+	})
+}
@@ -0,0 +1,89 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"obsidian-ai-agent/internal/chroma"
+)
+
+// ChunkLister lists the bookkeeping metadata of all chunks in the collection
+type ChunkLister interface {
+	ListChunks(ctx context.Context) ([]chroma.ChunkInfo, error)
+}
+
+// CollectionStateStore reconstructs the file index from the content_hash and last_modified
+// metadata stored on the chunks themselves, so no state needs to be kept anywhere else
+type CollectionStateStore struct {
+	lister    ChunkLister
+	vaultPath string
+}
+
+// NewCollectionStateStore creates a state store backed by the chunks in the collection
+func NewCollectionStateStore(lister ChunkLister, vaultPath string) *CollectionStateStore {
+	return &CollectionStateStore{
+		lister:    lister,
+		vaultPath: vaultPath,
+	}
+}
+
+// Load groups the chunks in the collection by file and rebuilds their index entries
+func (s *CollectionStateStore) Load(ctx context.Context) (map[string]FileIndex, error) {
+	chunks, err := s.lister.ListChunks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chunks: %w", err)
+	}
+
+	byPath := make(map[string][]chroma.ChunkInfo)
+	for _, chunk := range chunks {
+		if chunk.Path == "" {
+			continue
+		}
+		byPath[chunk.Path] = append(byPath[chunk.Path], chunk)
+	}
+
+	files := make(map[string]FileIndex, len(byPath))
+	for path, fileChunks := range byPath {
+		relPath, err := vaultRelativePath(s.vaultPath, path)
+		if err != nil {
+			log.Printf("Warning: skipping chunks of %s: %v", path, err)
+			continue
+		}
+
+		sort.Slice(fileChunks, func(i, j int) bool {
+			return fileChunks[i].ChunkIndex < fileChunks[j].ChunkIndex
+		})
+
+		// The most recently modified chunk describes the current version of the file;
+		// chunks of older versions are kept in ChunkIDs so they are cleaned up later
+		latest := fileChunks[0]
+		chunkIDs := make([]string, len(fileChunks))
+		for i, chunk := range fileChunks {
+			chunkIDs[i] = chunk.ID
+			if chunk.LastModified > latest.LastModified {
+				latest = chunk
+			}
+		}
+
+		files[relPath] = FileIndex{
+			Path:         relPath,
+			LastModified: time.Unix(latest.LastModified, 0),
+			ContentHash:  latest.ContentHash,
+			DocumentID:   chunkIDs[0],
+			ChunkIDs:     chunkIDs,
+
+			NormalizerVersion: int(latest.NormalizerVersion),
+			ChunkSettings:     latest.ChunkSettings,
+		}
+	}
+
+	return files, nil
+}
+
+// Save is a no-op: the state is stored on the chunks as they are upserted
+func (s *CollectionStateStore) Save(ctx context.Context, files map[string]FileIndex) error {
+	return nil
+}
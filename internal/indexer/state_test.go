@@ -0,0 +1,314 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"obsidian-ai-agent/internal/chroma"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newStateTestVault creates a vault with a single note and indexes it once
+func newStateTestVault(t *testing.T) (string, string) {
+	t.Helper()
+
+	vaultDir := t.TempDir()
+	notesDir := filepath.Join(vaultDir, "notes")
+	require.NoError(t, os.MkdirAll(notesDir, 0755))
+
+	testFile := filepath.Join(notesDir, "note.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("# Note\n\nContent that is long enough to be indexed."), 0644))
+
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath:    vaultDir,
+		BatchSize:    10,
+		Directories:  []string{"notes"},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	})
+	_, err := indexer.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+
+	return vaultDir, testFile
+}
+
+func TestStateFileIsVersionedAndVaultRelative(t *testing.T) {
+	vaultDir, _ := newStateTestVault(t)
+
+	data, err := os.ReadFile(filepath.Join(vaultDir, IndexFileName))
+	require.NoError(t, err)
+
+	var state indexState
+	require.NoError(t, json.Unmarshal(data, &state))
+	assert.Equal(t, currentStateVersion, state.Version)
+	require.Contains(t, state.Files, "notes/note.md")
+	assert.Equal(t, "notes/note.md", state.Files["notes/note.md"].Path)
+
+	// No temporary files are left behind by the atomic write
+	entries, err := os.ReadDir(vaultDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.Contains(entry.Name(), ".tmp-"), "unexpected temporary file %s", entry.Name())
+	}
+}
+
+func TestStateFileSurvivesDifferentVaultPathForms(t *testing.T) {
+	vaultDir, testFile := newStateTestVault(t)
+
+	// Refer to the same vault through a different, unclean path
+	otherForm := filepath.Join(vaultDir, "notes", "..")
+	mockClient := NewMockChromaClient()
+	indexer := NewObsidianIndexer(mockClient, &Config{
+		VaultPath:    otherForm,
+		BatchSize:    10,
+		Directories:  []string{"notes"},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	})
+
+	require.Contains(t, indexer.fileIndex, testFile)
+
+	result, err := indexer.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SkippedFiles)
+	assert.Equal(t, 0, mockClient.GetUpsertCallCount())
+}
+
+func TestLegacyStateFileIsMigrated(t *testing.T) {
+	vaultDir, testFile := newStateTestVault(t)
+	indexFile := filepath.Join(vaultDir, IndexFileName)
+
+	// Rewrite the state as a version 1 file keyed by the walked path
+	data, err := os.ReadFile(indexFile)
+	require.NoError(t, err)
+	var state indexState
+	require.NoError(t, json.Unmarshal(data, &state))
+
+	entry := state.Files["notes/note.md"]
+	entry.Path = testFile
+	legacy, err := json.Marshal(map[string]FileIndex{testFile: entry})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(indexFile, legacy, 0644))
+
+	mockClient := NewMockChromaClient()
+	indexer := NewObsidianIndexer(mockClient, &Config{
+		VaultPath:    vaultDir,
+		BatchSize:    10,
+		Directories:  []string{"notes"},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+	})
+
+	result, err := indexer.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SkippedFiles, "migrated entry should keep the file from being reindexed")
+
+	data, err = os.ReadFile(indexFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &state))
+	assert.Equal(t, currentStateVersion, state.Version)
+	assert.Contains(t, state.Files, "notes/note.md")
+}
+
+func TestCorruptStateFileIsPreserved(t *testing.T) {
+	vaultDir := t.TempDir()
+	indexFile := filepath.Join(vaultDir, IndexFileName)
+	require.NoError(t, os.WriteFile(indexFile, []byte(`{"version": 2, "files": {"notes/no`), 0644))
+
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath:   vaultDir,
+		BatchSize:   10,
+		Directories: []string{"notes"},
+	})
+
+	assert.Empty(t, indexer.fileIndex)
+	_, err := os.Stat(indexFile + ".corrupt")
+	assert.NoError(t, err, "corrupt state file should be kept for inspection")
+}
+
+func TestStateLockIsHeldForTheRun(t *testing.T) {
+	vaultDir, _ := newStateTestVault(t)
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath:   vaultDir,
+		BatchSize:   10,
+		Directories: []string{"notes"},
+	})
+
+	// Another process holding the lock makes a run fail at once instead of waiting
+	release, err := lockFile(filepath.Join(vaultDir, IndexFileName+".lock"))
+	require.NoError(t, err)
+	_, err = indexer.ReindexVault(context.Background(), []string{"notes"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "held by another process")
+	release()
+
+	// The run's lock is released afterwards and does not block its own saves
+	_, err = indexer.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+	release, err = lockFile(filepath.Join(vaultDir, IndexFileName+".lock"))
+	require.NoError(t, err)
+	release()
+}
+
+// TestRunsReloadStateSavedByAnotherProcess tests that two indexers sharing a state file start each
+// run from what the other saved, instead of re-embedding its notes and overwriting its entries
+func TestRunsReloadStateSavedByAnotherProcess(t *testing.T) {
+	vaultDir, testFile := newStateTestVault(t)
+	config := func() *Config {
+		return &Config{VaultPath: vaultDir, BatchSize: 10, Directories: []string{"notes"}, ChunkSize: 200, ChunkOverlap: 50}
+	}
+
+	// The second process starts while the first holds the lock, so it cannot load the state yet
+	release, err := lockFile(filepath.Join(vaultDir, IndexFileName+".lock"))
+	require.NoError(t, err)
+	firstClient, secondClient := NewMockChromaClient(), NewMockChromaClient()
+	first := NewObsidianIndexer(firstClient, config())
+	second := NewObsidianIndexer(secondClient, config())
+	assert.Empty(t, second.fileIndex)
+	release()
+
+	// Each run picks up the notes the other process indexed in the meantime
+	otherFile := filepath.Join(vaultDir, "notes", "other.md")
+	require.NoError(t, os.WriteFile(otherFile, []byte("# Other\n\nWritten while the second process was idle."), 0644))
+	result, err := first.IndexFiles(context.Background(), []string{otherFile})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.IndexedFiles)
+
+	result, err = second.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.SkippedFiles)
+	assert.Zero(t, secondClient.GetUpsertCallCount(), "notes indexed by the other process are not embedded again")
+
+	require.NoError(t, os.WriteFile(testFile, []byte("# Note\n\nEdited and indexed by the second process."), 0644))
+	result, err = second.IndexFiles(context.Background(), []string{testFile})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.UpdatedFiles)
+
+	result, err = first.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.SkippedFiles, "the first process sees the second one's update")
+	assert.Equal(t, 1, firstClient.GetUpsertCallCount())
+
+	files, err := NewJSONStateStore(filepath.Join(vaultDir, IndexFileName), vaultDir).Load(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, files, "notes/note.md")
+	assert.Contains(t, files, "notes/other.md")
+}
+
+// TestRunFailsWhenStateCannotBeLoaded tests that a run does not continue with an empty index
+func TestRunFailsWhenStateCannotBeLoaded(t *testing.T) {
+	vaultDir, _ := newStateTestVault(t)
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{VaultPath: vaultDir, BatchSize: 10, Directories: []string{"notes"}})
+
+	require.NoError(t, os.WriteFile(filepath.Join(vaultDir, IndexFileName), []byte("{not json"), 0644))
+	_, err := indexer.ReindexVault(context.Background(), []string{"notes"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to load file index")
+}
+
+func TestBoltStateStoreRoundTrip(t *testing.T) {
+	vaultDir := t.TempDir()
+	notesDir := filepath.Join(vaultDir, "notes")
+	require.NoError(t, os.MkdirAll(notesDir, 0755))
+	testFile := filepath.Join(notesDir, "note.md")
+	require.NoError(t, os.WriteFile(testFile, []byte("# Note\n\nContent that is long enough to be indexed."), 0644))
+
+	// The database lives outside the vault
+	store, err := OpenBoltStateStore(filepath.Join(t.TempDir(), "state.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	config := &Config{
+		VaultPath:    vaultDir,
+		BatchSize:    10,
+		Directories:  []string{"notes"},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+		StateStore:   store,
+	}
+	_, err = NewObsidianIndexer(NewMockChromaClient(), config).ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(vaultDir, IndexFileName))
+	assert.True(t, os.IsNotExist(err), "no state file should be written into the vault")
+
+	files, err := store.Load(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, files, "notes/note.md")
+
+	mockClient := NewMockChromaClient()
+	result, err := NewObsidianIndexer(mockClient, config).ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SkippedFiles)
+	assert.Equal(t, 0, mockClient.GetUpsertCallCount())
+}
+
+// fakeChunkLister returns a fixed set of chunks
+type fakeChunkLister []chroma.ChunkInfo
+
+func (f fakeChunkLister) ListChunks(ctx context.Context) ([]chroma.ChunkInfo, error) {
+	return f, nil
+}
+
+func TestCollectionStateStoreRebuildsState(t *testing.T) {
+	vaultDir, testFile := newStateTestVault(t)
+
+	// Rebuild the state from the chunk metadata the first run stored
+	first := NewMockChromaClient()
+	indexer := NewObsidianIndexer(first, &Config{
+		VaultPath:    vaultDir,
+		BatchSize:    10,
+		Directories:  []string{"notes"},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+		StateStore:   NewJSONStateStore(filepath.Join(t.TempDir(), IndexFileName), vaultDir),
+	})
+	_, err := indexer.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+
+	var chunks fakeChunkLister
+	for _, batch := range first.UpsertCalls {
+		for _, doc := range batch {
+			chunks = append(chunks, chroma.ChunkInfo{
+				ID:           doc.ID,
+				Path:         doc.Metadata["path"].(string),
+				ChunkIndex:   int64(doc.Metadata["chunk_index"].(int)),
+				ContentHash:  doc.Metadata["content_hash"].(string),
+				LastModified: doc.Metadata["last_modified"].(int64),
+
+				NormalizerVersion: int64(doc.Metadata["normalizer_version"].(int)),
+				ChunkSettings:     doc.Metadata["chunk_settings"].(string),
+			})
+		}
+	}
+	require.NotEmpty(t, chunks)
+
+	store := NewCollectionStateStore(chunks, vaultDir)
+	files, err := store.Load(context.Background())
+	require.NoError(t, err)
+	require.Contains(t, files, "notes/note.md")
+	assert.Len(t, files["notes/note.md"].ChunkIDs, len(chunks))
+
+	// A run against the rebuilt state skips the unchanged note
+	mockClient := NewMockChromaClient()
+	rebuilt := NewObsidianIndexer(mockClient, &Config{
+		VaultPath:    vaultDir,
+		BatchSize:    10,
+		Directories:  []string{"notes"},
+		ChunkSize:    200,
+		ChunkOverlap: 50,
+		StateStore:   store,
+	})
+	require.Contains(t, rebuilt.fileIndex, testFile)
+
+	result, err := rebuilt.ReindexVault(context.Background(), []string{"notes"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.SkippedFiles)
+	assert.Equal(t, 0, mockClient.GetUpsertCallCount())
+}
@@ -0,0 +1,102 @@
+package indexer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tagMetadataPrefix prefixes the boolean per-tag metadata keys, e.g. "tag:project" = true,
+// which allow filtering chunks by tag with a plain equality filter
+const tagMetadataPrefix = "tag:"
+
+var (
+	// inlineTagRegex matches #tags preceded by the start of the line, whitespace or an opening bracket
+	inlineTagRegex   = regexp.MustCompile(`(^|[\s(\[])#([\p{L}\p{N}_\-/]+)`)
+	inlineCodeRegex  = regexp.MustCompile("`[^`\n]*`")
+	inlineURLRegex   = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.\-]*://\S+`)
+	tagWikilinkRegex = regexp.MustCompile(`\[\[[^\]\n]*\]\]`)
+	linkTargetRegex  = regexp.MustCompile(`\]\([^)\n]*\)`)
+	headingLineRegex = regexp.MustCompile(`^\s{0,3}#{1,6}(\s|$)`)
+	numericTagRegex  = regexp.MustCompile(`^[0-9/]+$`)
+)
+
+// extractInlineTags returns the normalised #tags in a note body, skipping fenced code blocks,
+// inline code, URLs, wikilinks, link destinations and heading lines
+func extractInlineTags(body string) []string {
+	var tags []string
+
+	for _, line := range proseLines(body) {
+		if headingLineRegex.MatchString(line) {
+			continue
+		}
+
+		line = inlineURLRegex.ReplaceAllString(line, " ")
+		// Same-note links such as [[#Heading]] and [see](#setup) are not tags
+		line = tagWikilinkRegex.ReplaceAllString(line, " ")
+		line = linkTargetRegex.ReplaceAllString(line, "]")
+
+		for _, match := range inlineTagRegex.FindAllStringSubmatch(line, -1) {
+			tags = append(tags, match[2])
+		}
+	}
+
+	return normalizeTags(tags)
+}
+
+// normalizeTags lowercases tags, strips the leading "#" and surrounding slashes, and drops
+// duplicates and purely numeric tags (which Obsidian does not treat as tags)
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+
+	for _, tag := range tags {
+		tag = strings.Trim(strings.TrimSpace(tag), `"'`)
+		tag = strings.ToLower(strings.Trim(strings.TrimPrefix(tag, "#"), "/"))
+		if tag == "" || numericTagRegex.MatchString(tag) || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
+
+// mergeTags combines tag lists, keeping the order of first appearance
+func mergeTags(lists ...[]string) []string {
+	var all []string
+	for _, list := range lists {
+		all = append(all, list...)
+	}
+	return normalizeTags(all)
+}
+
+// expandNestedTags adds the parent tags of nested tags, so "project/alpha" also yields "project"
+func expandNestedTags(tags []string) []string {
+	var expanded []string
+	for _, tag := range tags {
+		parts := strings.Split(tag, "/")
+		for i := 1; i <= len(parts); i++ {
+			expanded = append(expanded, strings.Join(parts[:i], "/"))
+		}
+	}
+	return normalizeTags(expanded)
+}
+
+// tagMetadata returns the chunk metadata for a note's tags: the expanded tag list and a
+// boolean key per tag
+func tagMetadata(tags []string) map[string]interface{} {
+	expanded := expandNestedTags(tags)
+	if len(expanded) == 0 {
+		return nil
+	}
+
+	metadata := map[string]interface{}{
+		"tags": strings.Join(expanded, ", "),
+	}
+	for _, tag := range expanded {
+		metadata[tagMetadataPrefix+tag] = true
+	}
+
+	return metadata
+}
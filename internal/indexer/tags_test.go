@@ -0,0 +1,104 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExtractInlineTags tests inline tag extraction from note bodies
+func TestExtractInlineTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name:     "simple and nested tags",
+			body:     "Discussed #meeting notes for #project/alpha.\n#Idea at line start",
+			expected: []string{"meeting", "project/alpha", "idea"},
+		},
+		{
+			name:     "duplicates and case are normalised",
+			body:     "#Meeting and #meeting and #MEETING",
+			expected: []string{"meeting"},
+		},
+		{
+			name:     "headings are skipped",
+			body:     "# Heading\n## Weekly #sync\nBody with #tag",
+			expected: []string{"tag"},
+		},
+		{
+			name:     "code is skipped",
+			body:     "```bash\n# comment #notatag\n```\nUse `#define` in C, but #real counts",
+			expected: []string{"real"},
+		},
+		{
+			name:     "urls and links are skipped",
+			body:     "See https://example.com/page#section and [[Note#Heading]] or [text](https://x.org/#frag)",
+			expected: nil,
+		},
+		{
+			name:     "same-note links are skipped",
+			body:     "Jump to [[#Heading]] or [[#^block|the block]], [see](#setup) and [[Note#Part]] #kept",
+			expected: []string{"kept"},
+		},
+		{
+			name:     "numeric tags are not tags",
+			body:     "Issue #123 and #2024/q1 but #y2024",
+			expected: []string{"2024/q1", "y2024"},
+		},
+		{
+			name:     "tags in parentheses and lists",
+			body:     "- item (#todo)\n- [#later]",
+			expected: []string{"todo", "later"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, extractInlineTags(tt.body))
+		})
+	}
+}
+
+// TestExpandNestedTags tests that nested tags also match their parents
+func TestExpandNestedTags(t *testing.T) {
+	assert.Equal(t,
+		[]string{"project", "project/alpha", "project/alpha/design", "meeting", "project/beta"},
+		expandNestedTags([]string{"project/alpha/design", "meeting", "project/beta"}))
+}
+
+// TestTagMetadata tests that inline and frontmatter tags end up as filterable chunk metadata
+func TestTagMetadata(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := filepath.Join(tempDir, "meeting.md")
+	content := `---
+tags: [Meeting, "#project/alpha"]
+---
+
+# Weekly sync
+
+Talked about hiring #people/hiring and the #meeting cadence.`
+	require.NoError(t, os.WriteFile(testFile, []byte(content), 0644))
+
+	indexer := &ObsidianIndexer{
+		vaultPath:    tempDir,
+		chunkSize:    2000,
+		chunkOverlap: 200,
+	}
+
+	chunks, _, err := indexer.processFileWithChunks(testFile)
+	require.NoError(t, err)
+	require.NotEmpty(t, chunks)
+
+	for _, chunk := range chunks {
+		assert.Equal(t, "meeting, project, project/alpha, people, people/hiring", chunk.Metadata["tags"])
+		for _, tag := range []string{"meeting", "project", "project/alpha", "people", "people/hiring"} {
+			assert.Equal(t, true, chunk.Metadata["tag:"+tag], "missing tag %s", tag)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package indexer
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"obsidian-ai-agent/internal/chroma"
+)
+
+// TaskChunkType is the chunk_type of documents holding a single checklist task
+const TaskChunkType = "task"
+
+// Task statuses stored in the task_status metadata
+const (
+	TaskStatusOpen       = "open"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusDone       = "done"
+	TaskStatusCancelled  = "cancelled"
+	TaskStatusOther      = "other"
+)
+
+var (
+	// taskLineRegex matches checklist items such as "- [ ] text", "* [x] text" or "1. [/] text"
+	taskLineRegex = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[(.)\]\s+(.*)$`)
+
+	// taskDateRegex matches the Tasks plugin date fields, e.g. "📅 2025-10-31"
+	taskDateRegex = regexp.MustCompile(`(📅|⏳|🛫|✅|➕|❌)\x{FE0F}?\s*(\d{4}-\d{2}-\d{2})`)
+
+	// taskRecurrenceRegex matches a recurrence rule up to the next emoji field, e.g. "🔁 every week"
+	taskRecurrenceRegex = regexp.MustCompile(`🔁\x{FE0F}?\s*[^📅⏳🛫✅➕❌🔺⏫🔼🔽⏬^]*`)
+
+	taskPriorityRegex = regexp.MustCompile(`(🔺|⏫|🔼|🔽|⏬)\x{FE0F}?`)
+	taskBlockIDRegex  = regexp.MustCompile(`\s\^[\w-]+\s*$`)
+)
+
+// taskDateFields maps the Tasks plugin date emojis to metadata keys
+var taskDateFields = map[string]string{
+	"📅": "task_due",
+	"⏳": "task_scheduled",
+	"🛫": "task_start",
+	"✅": "task_done",
+	"➕": "task_created",
+	"❌": "task_cancelled",
+}
+
+// taskFieldKeys maps the Dataview task fields understood by the Tasks plugin to metadata keys
+var taskFieldKeys = map[string]string{
+	"due":        "task_due",
+	"scheduled":  "task_scheduled",
+	"start":      "task_start",
+	"completion": "task_done",
+	"created":    "task_created",
+	"cancelled":  "task_cancelled",
+}
+
+// taskPriorities maps the Tasks plugin priority emojis to priority names
+var taskPriorities = map[string]string{
+	"🔺": "highest",
+	"⏫": "high",
+	"🔼": "medium",
+	"🔽": "low",
+	"⏬": "lowest",
+}
+
+// task is a checklist item of a note
+type task struct {
+	Text     string               // Description without status, dates or priority
+	Status   string               // One of the TaskStatus constants
+	Dates    map[string]time.Time // Dates by metadata key, e.g. "task_due"
+	Priority string               // Priority name; empty if none is set
+	Heading  string               // Closest heading above the task
+	Line     int                  // 1-based line number in the note
+}
+
+// parseTask parses a checklist line, reporting false if the line is not a task
+func parseTask(line string) (task, bool) {
+	match := taskLineRegex.FindStringSubmatch(line)
+	if match == nil {
+		return task{}, false
+	}
+
+	t := task{Status: taskStatus(match[1]), Dates: make(map[string]time.Time)}
+	text := match[2]
+
+	for _, field := range taskDateRegex.FindAllStringSubmatch(text, -1) {
+		if date, err := time.Parse("2006-01-02", field[2]); err == nil {
+			t.Dates[taskDateFields[field[1]]] = date
+		}
+	}
+	if priority := taskPriorityRegex.FindStringSubmatch(text); priority != nil {
+		t.Priority = taskPriorities[priority[1]]
+	}
+
+	// Dataview format, e.g. "[due:: 2025-10-31]" and "[priority:: high]"
+	text = bracketFieldRegex.ReplaceAllStringFunc(text, func(match string) string {
+		groups := bracketFieldRegex.FindStringSubmatch(match)
+		key, value := groups[1], groups[2]
+		if key == "" {
+			key, value = groups[3], groups[4]
+		}
+		key = canonicalFieldKey(key)
+
+		if dateKey, ok := taskFieldKeys[key]; ok {
+			if date, err := time.Parse("2006-01-02", strings.TrimSpace(value)); err == nil {
+				t.Dates[dateKey] = date
+				return ""
+			}
+		}
+		if key == "priority" && value != "" {
+			t.Priority = strings.ToLower(strings.TrimSpace(value))
+			return ""
+		}
+		return match
+	})
+
+	text = taskDateRegex.ReplaceAllString(text, "")
+	text = taskRecurrenceRegex.ReplaceAllString(text, "")
+	text = taskPriorityRegex.ReplaceAllString(text, "")
+	text = taskBlockIDRegex.ReplaceAllString(text, "")
+	t.Text = strings.Join(strings.Fields(text), " ")
+	if t.Text == "" {
+		return task{}, false
+	}
+
+	return t, true
+}
+
+// taskStatus maps a checkbox character to a task status
+func taskStatus(mark string) string {
+	switch mark {
+	case " ":
+		return TaskStatusOpen
+	case "x", "X":
+		return TaskStatusDone
+	case "/":
+		return TaskStatusInProgress
+	case "-":
+		return TaskStatusCancelled
+	default:
+		return TaskStatusOther
+	}
+}
+
+// extractTasks returns the tasks of a note outside its frontmatter and fenced code blocks,
+// with the heading each task is under
+func extractTasks(content string) []task {
+	var tasks []task
+
+	lines := strings.Split(content, "\n")
+
+	heading, inFence := "", ""
+	for i := bodyStartLine(content); i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = trimmed[:3]
+			continue
+		}
+
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			heading = match[2]
+			continue
+		}
+
+		if t, ok := parseTask(line); ok {
+			t.Heading = heading
+			t.Line = i + 1
+			tasks = append(tasks, t)
+		}
+	}
+
+	return tasks
+}
+
+// taskDocuments turns the tasks of a note into documents of their own, so they can be searched
+// and filtered by status, dates and priority
+func (idx *ObsidianIndexer) taskDocuments(filePath, content string) []chroma.Document {
+	tasks := extractTasks(content)
+	documents := make([]chroma.Document, 0, len(tasks))
+
+	for i, t := range tasks {
+		text := idx.cleanContent(t.Text)
+		if text == "" {
+			continue
+		}
+
+		metadata := map[string]interface{}{
+			"path":        filePath,
+			"filename":    filepath.Base(filePath),
+			"folder":      filepath.Dir(filePath),
+			"chunk_index": i,
+			"chunk_type":  TaskChunkType,
+			"task_status": t.Status,
+			"task_text":   text,
+			"task_line":   t.Line,
+		}
+		for key, date := range t.Dates {
+			metadata[key] = date.Unix()
+		}
+		if t.Priority != "" {
+			metadata["task_priority"] = t.Priority
+		}
+		if t.Heading != "" {
+			metadata["heading"] = t.Heading
+		}
+
+		content := idx.cleanContent(taskContent(t, text, filePath))
+		documents = append(documents, chroma.Document{
+			ID:       generateChunkID(filePath, content, 0),
+			Content:  content,
+			Metadata: metadata,
+		})
+	}
+
+	return documents
+}
+
+// taskContent renders a task as the text that is embedded, e.g.
+// "Open task: Hire a designer. Due 2025-10-31. Priority high. In Hiring > Candidates."
+func taskContent(t task, text, filePath string) string {
+	label := strings.ReplaceAll(t.Status, "_", " ")
+	parts := []string{strings.ToUpper(label[:1]) + label[1:] + " task: " + strings.TrimRight(text, ".")}
+
+	for _, field := range []struct{ key, label string }{
+		{"task_due", "Due"},
+		{"task_scheduled", "Scheduled"},
+		{"task_start", "Starts"},
+		{"task_done", "Done"},
+	} {
+		if date, ok := t.Dates[field.key]; ok {
+			parts = append(parts, field.label+" "+date.Format("2006-01-02"))
+		}
+	}
+	if t.Priority != "" {
+		parts = append(parts, "Priority "+t.Priority)
+	}
+
+	source := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	if t.Heading != "" {
+		source += " > " + t.Heading
+	}
+	parts = append(parts, "In "+source)
+
+	return strings.Join(parts, ". ") + "."
+}
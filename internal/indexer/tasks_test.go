@@ -0,0 +1,145 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseTask tests parsing checklist items with Tasks plugin fields
+func TestParseTask(t *testing.T) {
+	date := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02", value)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	tests := []struct {
+		name     string
+		line     string
+		expected task
+		ok       bool
+	}{
+		{
+			name:     "open task",
+			line:     "- [ ] Write the report",
+			expected: task{Text: "Write the report", Status: TaskStatusOpen, Dates: map[string]time.Time{}},
+			ok:       true,
+		},
+		{
+			name: "done task with dates and priority",
+			line: "  * [x] Interview candidates #hiring ⏫ 📅 2025-10-31 ⏳ 2025-10-20 ✅ 2025-10-21 ^abc",
+			expected: task{
+				Text:   "Interview candidates #hiring",
+				Status: TaskStatusDone,
+				Dates: map[string]time.Time{
+					"task_due":       date("2025-10-31"),
+					"task_scheduled": date("2025-10-20"),
+					"task_done":      date("2025-10-21"),
+				},
+				Priority: "high",
+			},
+			ok: true,
+		},
+		{
+			name: "recurring numbered task",
+			line: "1. [/] Water plants 🔁 every week 🛫 2025-01-06",
+			expected: task{
+				Text:   "Water plants",
+				Status: TaskStatusInProgress,
+				Dates:  map[string]time.Time{"task_start": date("2025-01-06")},
+			},
+			ok: true,
+		},
+		{
+			name:     "cancelled task",
+			line:     "- [-] Old idea 🔽",
+			expected: task{Text: "Old idea", Status: TaskStatusCancelled, Dates: map[string]time.Time{}, Priority: "low"},
+			ok:       true,
+		},
+		{name: "plain list item", line: "- Not a task"},
+		{name: "empty task", line: "- [ ] "},
+		{name: "link that looks like a checkbox", line: "[x] marks the spot"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, ok := parseTask(tt.line)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.expected, parsed)
+			}
+		})
+	}
+}
+
+// TestExtractTasks tests task extraction with headings, line numbers and code blocks
+func TestExtractTasks(t *testing.T) {
+	content := "---\ntags: [project]\n---\n- [ ] Before any heading\n\n## Hiring\n\n- [ ] Post the job ad\n" +
+		"```\n- [ ] Example in code\n```\n### Interviews\n- [x] Book rooms"
+
+	tasks := extractTasks(content)
+	require.Len(t, tasks, 3)
+
+	assert.Equal(t, "Before any heading", tasks[0].Text)
+	assert.Empty(t, tasks[0].Heading)
+	assert.Equal(t, 4, tasks[0].Line)
+
+	assert.Equal(t, "Post the job ad", tasks[1].Text)
+	assert.Equal(t, "Hiring", tasks[1].Heading)
+	assert.Equal(t, 8, tasks[1].Line)
+
+	assert.Equal(t, "Interviews", tasks[2].Heading)
+	assert.Equal(t, TaskStatusDone, tasks[2].Status)
+}
+
+// TestTaskDocuments tests that tasks are indexed as separate documents with task metadata
+func TestTaskDocuments(t *testing.T) {
+	vaultDir := t.TempDir()
+	notePath := filepath.Join(vaultDir, "Hiring.md")
+	content := "---\nproject: atlas\n---\n# Hiring\n\nWe need two engineers.\n\n## Next steps\n\n" +
+		"- [ ] Interview [[Jane Doe]] for the backend role 📅 2025-10-31 🔺\n- [x] Post the job ad ✅ 2025-10-01\n" +
+		"%%\n- [ ] Private task\n%%\n"
+	require.NoError(t, os.WriteFile(notePath, []byte(content), 0644))
+
+	indexer := &ObsidianIndexer{vaultPath: vaultDir, chunkSize: 1000, chunkOverlap: 100}
+	chunks, _, err := indexer.processFileWithChunks(notePath)
+	require.NoError(t, err)
+
+	var tasks []map[string]interface{}
+	var contents []string
+	for _, chunk := range chunks {
+		if chunk.Metadata["chunk_type"] == TaskChunkType {
+			tasks = append(tasks, chunk.Metadata)
+			contents = append(contents, chunk.Content)
+		}
+	}
+	require.Len(t, tasks, 2, "tasks in comments are not indexed")
+
+	open := tasks[0]
+	assert.Equal(t, TaskStatusOpen, open["task_status"])
+	assert.Equal(t, "Interview Jane Doe for the backend role", open["task_text"])
+	assert.Equal(t, time.Date(2025, 10, 31, 0, 0, 0, 0, time.UTC).Unix(), open["task_due"])
+	assert.Equal(t, "highest", open["task_priority"])
+	assert.Equal(t, "Next steps", open["heading"])
+	assert.Equal(t, 10, open["task_line"])
+	assert.Equal(t, notePath, open["path"])
+	assert.Equal(t, "atlas", open["project"], "tasks inherit the note's frontmatter")
+	assert.Equal(t, "Open task: Interview Jane Doe for the backend role. Due 2025-10-31. Priority highest. In Hiring > Next steps.", contents[0])
+
+	done := tasks[1]
+	assert.Equal(t, TaskStatusDone, done["task_status"])
+	assert.Contains(t, done, "task_done")
+	assert.NotContains(t, done, "task_due")
+
+	// Task IDs do not collide with the IDs of content chunks
+	ids := make(map[string]bool)
+	for _, chunk := range chunks {
+		assert.False(t, ids[chunk.ID], "duplicate chunk ID %s", chunk.ID)
+		ids[chunk.ID] = true
+	}
+}
@@ -0,0 +1,97 @@
+package indexer
+
+import (
+	"log"
+	"strings"
+
+	"obsidian-ai-agent/internal/chroma"
+)
+
+// maxReportedTruncations limits how many truncated chunks are listed at the end of a run
+const maxReportedTruncations = 20
+
+// textSize measures cleaned text in the unit of chunkSize: tokens of the embedding model when
+// chunks are sized in tokens, characters otherwise
+func (idx *ObsidianIndexer) textSize(text string) int {
+	if idx.tokenSizing {
+		return idx.tokenizer.Count(text)
+	}
+	return len(text)
+}
+
+// separatorSize is the size of the space that joins cleaned blocks; the tokenizer splits at spaces,
+// so it adds no tokens
+func (idx *ObsidianIndexer) separatorSize() int {
+	if idx.tokenSizing {
+		return 0
+	}
+	return 1
+}
+
+// splitByTokens splits cleaned content at word boundaries into chunks of at most chunkSize tokens.
+// The next chunk repeats trailing words of the previous one up to overlap tokens. A single word
+// longer than chunkSize becomes a chunk of its own.
+func (idx *ObsidianIndexer) splitByTokens(content string, chunkSize, overlap int) []string {
+	words := strings.Fields(content)
+	sizes := make([]int, len(words))
+	for i, word := range words {
+		sizes[i] = idx.tokenizer.Count(word)
+	}
+
+	var chunks []string
+	for start := 0; start < len(words); {
+		end, size := start, 0
+		for end < len(words) && (end == start || size+sizes[end] <= chunkSize) {
+			size += sizes[end]
+			end++
+		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			break
+		}
+
+		// Repeat trailing words, but always move forward
+		next, carried := end, 0
+		for next-1 > start && carried+sizes[next-1] <= overlap {
+			next--
+			carried += sizes[next]
+		}
+		start = next
+	}
+
+	return chunks
+}
+
+// truncatedChunks returns the chunks that are longer than the embedding model's input limit
+func (idx *ObsidianIndexer) truncatedChunks(chunks []chroma.Document) []TruncatedChunk {
+	if idx.tokenizer == nil {
+		return nil
+	}
+
+	var truncated []TruncatedChunk
+	for _, chunk := range chunks {
+		if tokens := idx.tokenizer.Count(chunk.Content); tokens > idx.tokenizer.MaxTokens() {
+			path, _ := chunk.Metadata["path"].(string)
+			chunkIndex, _ := chunk.Metadata["chunk_index"].(int)
+			truncated = append(truncated, TruncatedChunk{Path: path, ChunkIndex: chunkIndex, Tokens: tokens})
+		}
+	}
+	return truncated
+}
+
+// logTruncatedChunks reports the chunks of the run that the embedding model truncates
+func (idx *ObsidianIndexer) logTruncatedChunks(result *IndexResult) {
+	if idx.tokenizer == nil || len(result.TruncatedChunks) == 0 {
+		return
+	}
+
+	log.Printf("Truncation report: %d embedded chunks exceed the model's limit of %d tokens; their tail is not embedded",
+		len(result.TruncatedChunks), idx.tokenizer.MaxTokens())
+	for i, chunk := range result.TruncatedChunks {
+		if i == maxReportedTruncations {
+			log.Printf("  ... and %d more", len(result.TruncatedChunks)-i)
+			break
+		}
+		log.Printf("  %s chunk %d: %d tokens", chunk.Path, chunk.ChunkIndex, chunk.Tokens)
+	}
+}
@@ -0,0 +1,80 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordTokenizer counts every word as a token, with a fixed model input limit
+type wordTokenizer struct {
+	limit int
+}
+
+func (w wordTokenizer) Count(text string) int { return len(strings.Fields(text)) }
+func (w wordTokenizer) MaxTokens() int        { return w.limit }
+
+// TestSplitByTokens tests splitting at word boundaries by token count with token overlap
+func TestSplitByTokens(t *testing.T) {
+	indexer := &ObsidianIndexer{tokenizer: wordTokenizer{limit: 100}, tokenSizing: true}
+
+	chunks := indexer.splitByTokens("one two three four five six seven eight nine", 4, 1)
+	assert.Equal(t, []string{"one two three four", "four five six seven", "seven eight nine"}, chunks)
+
+	chunks = indexer.splitByTokens("one two three", 4, 1)
+	assert.Equal(t, []string{"one two three"}, chunks)
+
+	chunks = indexer.splitByTokens("one two three four five", 2, 5)
+	assert.Equal(t, []string{"one two", "two three", "three four", "four five"}, chunks, "overlap always moves forward")
+}
+
+// TestChunkContentByTokens tests that chunks sized in tokens never exceed the token limit
+func TestChunkContentByTokens(t *testing.T) {
+	config := &Config{VaultPath: t.TempDir(), Tokenizer: wordTokenizer{limit: 8}, ChunkTokens: 20, ChunkOverlapTokens: 2, ChunkSize: 2000}
+	indexer := NewObsidianIndexer(NewMockChromaClient(), config)
+	require.True(t, indexer.tokenSizing)
+	assert.Equal(t, 8, indexer.chunkSize, "chunk size is capped at the model's input limit")
+
+	content := "# Notes\n\nShort first paragraph here.\n\nA second paragraph that has quite a few more words in it than fit.\n\nEnd."
+	chunks := indexer.chunkContent(content, "/vault/notes.md")
+	require.NotEmpty(t, chunks)
+
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(strings.Fields(chunk.Content)), 8, chunk.Content)
+	}
+	assert.Equal(t, "# Notes Short first paragraph here. A second", chunks[0].Content, "the heading and short paragraph lead the long one")
+}
+
+// TestTruncationReport tests that chunks longer than the model's input limit are reported
+func TestTruncationReport(t *testing.T) {
+	tempDir := t.TempDir()
+	content := "# Long\n\n" + strings.Repeat("word ", 30) + "\n\n# Short\n\nJust a few words here."
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "long.md"), []byte(content), 0644))
+
+	indexer := NewObsidianIndexer(NewMockChromaClient(), &Config{
+		VaultPath:   tempDir,
+		BatchSize:   10,
+		ChunkSize:   2000,
+		Tokenizer:   wordTokenizer{limit: 10},
+		Directories: []string{"."},
+	})
+	assert.False(t, indexer.tokenSizing, "chunks are sized in characters without ChunkTokens")
+
+	result, err := indexer.ReindexVault(context.Background(), nil)
+	require.NoError(t, err)
+
+	require.Len(t, result.TruncatedChunks, 1)
+	assert.Equal(t, filepath.Join(tempDir, "long.md"), result.TruncatedChunks[0].Path)
+	assert.Equal(t, 0, result.TruncatedChunks[0].ChunkIndex)
+	assert.Equal(t, 32, result.TruncatedChunks[0].Tokens)
+
+	// Unchanged notes are not embedded again and not reported
+	result, err = indexer.ReindexVault(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.TruncatedChunks)
+}
@@ -0,0 +1,337 @@
+package indexer
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// headingPattern matches a markdown heading and captures its level and text
+var headingPattern = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)\s*#*\s*$`)
+
+// expandTransclusions replaces embeds of other notes ("![[Note]]", "![[Note#Heading]]" and
+// "![[Note#^block]]") with the embedded content, following nested embeds up to transclusionDepth
+// levels. It returns the expanded content and the content hashes of the embedded notes by
+// vault-relative path, so the note can be re-indexed when one of them changes.
+func (idx *ObsidianIndexer) expandTransclusions(filePath, content string) (string, map[string]string) {
+	if idx.transclusionDepth <= 0 {
+		return content, nil
+	}
+
+	source, err := vaultRelativePath(idx.vaultPath, filePath)
+	if err != nil {
+		return content, nil
+	}
+
+	transcluded := make(map[string]string)
+	expanded := idx.expandEmbeds(source, content, idx.transclusionDepth, map[string]bool{source: true}, transcluded)
+	if len(transcluded) == 0 {
+		return expanded, nil
+	}
+
+	return expanded, transcluded
+}
+
+// expandEmbeds inlines the embeds of content outside fenced code blocks. visiting holds the
+// notes on the current embed chain; embedding one of them again would be a cycle.
+func (idx *ObsidianIndexer) expandEmbeds(source, content string, depth int, visiting map[string]bool, transcluded map[string]string) string {
+	lines := strings.Split(content, "\n")
+	inFence := ""
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = trimmed[:3]
+			continue
+		}
+		if !strings.Contains(line, "![[") {
+			continue
+		}
+
+		lines[i] = wikiLinkPattern.ReplaceAllStringFunc(line, func(match string) string {
+			if !strings.HasPrefix(match, "!") {
+				return match
+			}
+			link := parseWikiLink(match[3 : len(match)-2])
+			if embedded, ok := idx.transcludeLink(source, link, depth, visiting, transcluded); ok {
+				return embedded
+			}
+			return match // Left for cleanContent to reduce to the link text
+		})
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// transcludeLink returns the content an embed refers to, with its own embeds expanded while
+// depth allows. It reports false for attachments, unresolved or opted-out notes, missing
+// sections and cycles.
+func (idx *ObsidianIndexer) transcludeLink(source string, link Link, depth int, visiting map[string]bool, transcluded map[string]string) (string, bool) {
+	if ext := path.Ext(link.Target); ext != "" && !strings.EqualFold(ext, ".md") {
+		return "", false // Attachment such as an image or PDF
+	}
+
+	target := idx.resolveEmbed(source, link.Target)
+	if target == "" || visiting[target] {
+		return "", false
+	}
+
+	data, err := os.ReadFile(filepath.Join(idx.vaultPath, filepath.FromSlash(target)))
+	if err != nil {
+		return "", false
+	}
+	transcluded[target] = fmt.Sprintf("%x", sha256.Sum256(data))
+
+	content := strings.ToValidUTF8(string(data), "")
+	yamlBlock, body, hasFrontmatter := splitYAMLFrontmatter(content)
+	if hasFrontmatter {
+		// Notes that opt out of indexing are not indexed as part of other notes either
+		if frontmatter, err := idx.parseYAMLFrontmatter(yamlBlock); err == nil && optsOutOfIndexing(frontmatter) {
+			return "", false
+		}
+	}
+
+	var ok bool
+	switch {
+	case link.Block != "":
+		body, ok = embeddedBlock(body, link.Block)
+	case link.Heading != "":
+		body, ok = embeddedSection(body, link.Heading)
+	default:
+		ok = true
+	}
+	if !ok {
+		return "", false
+	}
+
+	body = strings.TrimSpace(body)
+	if depth > 1 {
+		visiting[target] = true
+		body = idx.expandEmbeds(target, body, depth-1, visiting, transcluded)
+		delete(visiting, target)
+	}
+
+	return flattenHeadings(body), true
+}
+
+// flattenHeadings turns the headings of embedded content into plain lines, so the content stays
+// in the section of the note that embeds it when the note is chunked
+func flattenHeadings(content string) string {
+	lines := strings.Split(content, "\n")
+	inFence := ""
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if inFence != "" {
+			if strings.HasPrefix(trimmed, inFence) {
+				inFence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = trimmed[:3]
+			continue
+		}
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			lines[i] = match[2]
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// resolveEmbed returns the vault-relative path of an embedded note. Notes indexed in this run are
+// resolved by path or name; other notes in the vault only by path.
+func (idx *ObsidianIndexer) resolveEmbed(source, target string) string {
+	if idx.embedResolver != nil {
+		if note := idx.embedResolver.resolve(source, target); note != "" {
+			return note
+		}
+	}
+	if target == "" {
+		return ""
+	}
+
+	for _, candidate := range []string{path.Clean("/" + target), path.Join("/", path.Dir(source), target)} {
+		candidate = strings.TrimPrefix(candidate, "/")
+		if !strings.EqualFold(path.Ext(candidate), ".md") {
+			candidate += ".md"
+		}
+
+		fullPath := filepath.Join(idx.vaultPath, filepath.FromSlash(candidate))
+		if info, err := os.Stat(fullPath); err == nil && !info.IsDir() && !idx.isIgnored(fullPath, false) {
+			return candidate
+		}
+	}
+
+	return ""
+}
+
+// newEmbedResolver indexes the notes that embeds are resolved against during a run: the
+// tracked notes that did not vanish and the files of the run
+func (idx *ObsidianIndexer) newEmbedResolver(files []string, vanished map[string]FileIndex) *noteResolver {
+	seen := make(map[string]bool, len(idx.fileIndex)+len(files))
+	var notes []string
+
+	add := func(file string) {
+		if _, gone := vanished[file]; gone {
+			return
+		}
+		relPath, err := vaultRelativePath(idx.vaultPath, file)
+		if err != nil || seen[relPath] {
+			return
+		}
+		seen[relPath] = true
+		notes = append(notes, relPath)
+	}
+
+	for _, file := range sortedKeys(idx.fileIndex) {
+		add(file)
+	}
+	for _, file := range files {
+		add(file)
+	}
+
+	return newNoteResolver(notes)
+}
+
+// embeddedSection returns a heading and its content up to the next heading of the same or a
+// higher level. Nested heading links ("Note#Chapter#Section") refer to the last heading.
+func embeddedSection(body, heading string) (string, bool) {
+	if i := strings.LastIndex(heading, "#"); i >= 0 {
+		heading = heading[i+1:]
+	}
+	heading = strings.TrimSpace(heading)
+
+	lines := strings.Split(body, "\n")
+	start, level := -1, 0
+	inFence := false
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		match := headingPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if start < 0 {
+			if strings.EqualFold(strings.TrimSpace(match[2]), heading) {
+				start, level = i, len(match[1])
+			}
+			continue
+		}
+		if len(match[1]) <= level {
+			return strings.Join(lines[start:i], "\n"), true
+		}
+	}
+
+	if start < 0 {
+		return "", false
+	}
+	return strings.Join(lines[start:], "\n"), true
+}
+
+// embeddedBlock returns the paragraph marked with a "^id" block ID, without the marker. A marker
+// on its own line refers to the block above it, such as a list or table.
+func embeddedBlock(body, id string) (string, bool) {
+	marker := regexp.MustCompile(`(^|\s)\^` + regexp.QuoteMeta(id) + `\s*$`)
+	lines := strings.Split(body, "\n")
+
+	for i, line := range lines {
+		if !marker.MatchString(line) {
+			continue
+		}
+
+		end := i + 1
+		if strings.TrimSpace(line) == "^"+id {
+			// Obsidian separates the marker of a structured block with a blank line
+			end = i
+			for end > 0 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+		} else {
+			lines[i] = strings.TrimRight(marker.ReplaceAllString(line, ""), " \t")
+		}
+
+		start := end
+		for start > 0 && strings.TrimSpace(lines[start-1]) != "" {
+			start--
+		}
+		if start == end {
+			return "", false
+		}
+		return strings.Join(lines[start:end], "\n"), true
+	}
+
+	return "", false
+}
+
+// transclusionsChanged reports whether a note embedded into an indexed note changed or disappeared
+func (idx *ObsidianIndexer) transclusionsChanged(entry FileIndex) bool {
+	for relPath, hash := range entry.Transcluded {
+		content, err := os.ReadFile(filepath.Join(idx.vaultPath, filepath.FromSlash(relPath)))
+		if err != nil || fmt.Sprintf("%x", sha256.Sum256(content)) != hash {
+			return true
+		}
+	}
+	return false
+}
+
+// hasEmbeds reports whether links contain an embed of another note
+func hasEmbeds(links []Link) bool {
+	for _, link := range links {
+		if link.Embed && link.Target != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// findEmbeddingNotes returns the tracked notes that embed one of the changed or vanished files,
+// as recorded when they were last indexed, excluding files already in seen
+func (idx *ObsidianIndexer) findEmbeddingNotes(changed []string, vanished map[string]FileIndex, seen map[string]bool) []string {
+	targets := make(map[string]bool, len(changed)+len(vanished))
+	for _, file := range changed {
+		if relPath, err := vaultRelativePath(idx.vaultPath, file); err == nil {
+			targets[relPath] = true
+		}
+	}
+	for file := range vanished {
+		if relPath, err := vaultRelativePath(idx.vaultPath, file); err == nil {
+			targets[relPath] = true
+		}
+	}
+
+	var embedding []string
+	for _, file := range sortedKeys(idx.fileIndex) {
+		if _, gone := vanished[file]; gone || seen[file] {
+			continue
+		}
+		for relPath := range idx.fileIndex[file].Transcluded {
+			if targets[relPath] {
+				seen[file] = true
+				embedding = append(embedding, file)
+				break
+			}
+		}
+	}
+
+	return embedding
+}
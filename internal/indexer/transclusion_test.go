@@ -0,0 +1,130 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEmbeddedSection tests extracting a heading section for ![[Note#Heading]] embeds
+func TestEmbeddedSection(t *testing.T) {
+	body := "# Title\n\nIntro\n\n## Goals\n\nShip it\n\n### Details\n\nMore\n\n```\n## Not a heading\n```\n\n## Risks\n\nNone"
+
+	section, ok := embeddedSection(body, "goals")
+	require.True(t, ok)
+	assert.Equal(t, "## Goals\n\nShip it\n\n### Details\n\nMore\n\n```\n## Not a heading\n```\n", section)
+
+	section, ok = embeddedSection(body, "Title#Risks")
+	require.True(t, ok)
+	assert.Equal(t, "## Risks\n\nNone", section)
+
+	_, ok = embeddedSection(body, "Missing")
+	assert.False(t, ok)
+}
+
+// TestEmbeddedBlock tests extracting a block for ![[Note#^id]] embeds
+func TestEmbeddedBlock(t *testing.T) {
+	body := "First paragraph\ncontinues here ^para\n\n- item one\n- item two\n\n^list\n\nLast"
+
+	block, ok := embeddedBlock(body, "para")
+	require.True(t, ok)
+	assert.Equal(t, "First paragraph\ncontinues here", block)
+
+	block, ok = embeddedBlock(body, "list")
+	require.True(t, ok)
+	assert.Equal(t, "- item one\n- item two", block)
+
+	_, ok = embeddedBlock(body, "missing")
+	assert.False(t, ok)
+}
+
+// TestExpandTransclusions tests inlining embeds with depth and cycle limits
+func TestExpandTransclusions(t *testing.T) {
+	vaultDir, indexer, _ := newIgnoreTestVault(t, map[string]string{
+		"Hub.md":         "# Hub\n\n![[Alpha]]\n\n![[Beta#Plan]]\n\n![[diagram.png]]\n\n![[Missing]]",
+		"Alpha.md":       "---\ntags: [x]\n---\nAlpha text ![[Gamma]]",
+		"Beta.md":        "# Beta\n\n## Plan\n\nBeta plan\n\n## Other\n\nNot embedded",
+		"Gamma.md":       "Gamma text ![[Alpha]]",
+		"Private.md":     "---\nindex: false\n---\nSecret",
+		"Uses Secret.md": "![[Private]]",
+	})
+	hub := filepath.Join(vaultDir, "Hub.md")
+	content, err := os.ReadFile(hub)
+	require.NoError(t, err)
+
+	// Disabled by default
+	expanded, transcluded := indexer.expandTransclusions(hub, string(content))
+	assert.Equal(t, string(content), expanded)
+	assert.Nil(t, transcluded)
+
+	indexer.transclusionDepth = 1
+	expanded, transcluded = indexer.expandTransclusions(hub, string(content))
+	assert.Contains(t, expanded, "Alpha text ![[Gamma]]", "nested embeds are kept beyond the depth limit")
+	assert.NotContains(t, expanded, "tags:", "frontmatter of embedded notes is dropped")
+	assert.Contains(t, expanded, "\nPlan\n\nBeta plan", "embedded headings do not start sections of the embedding note")
+	assert.NotContains(t, expanded, "Not embedded")
+	assert.Contains(t, expanded, "![[diagram.png]]")
+	assert.Contains(t, expanded, "![[Missing]]")
+	assert.Len(t, transcluded, 2)
+
+	indexer.transclusionDepth = 5
+	expanded, transcluded = indexer.expandTransclusions(hub, string(content))
+	assert.Contains(t, expanded, "Alpha text Gamma text ![[Alpha]]", "cycles stop at the repeated note")
+	assert.Contains(t, transcluded, "Gamma.md")
+
+	expanded, _ = indexer.expandTransclusions(filepath.Join(vaultDir, "Uses Secret.md"), "![[Private]]")
+	assert.Equal(t, "![[Private]]", expanded, "opted-out notes are not inlined")
+}
+
+// TestEmbeddingNotesAreReindexed tests that changing an embedded note re-indexes the notes embedding it
+func TestEmbeddingNotesAreReindexed(t *testing.T) {
+	vaultDir, indexer, mockClient := newIgnoreTestVault(t, map[string]string{
+		"Hub.md":            "# Hub\n\nOverview of the project:\n\n![[Projects/Alpha]]",
+		"Projects/Alpha.md": "# Alpha\n\nThe original plan for alpha.",
+		"Unrelated.md":      "# Unrelated\n\nSomething else entirely.",
+	})
+	indexer.transclusionDepth = 1
+
+	_, err := indexer.ReindexVault(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Contains(t, upsertedContent(mockClient, "Hub.md"), "original plan")
+
+	alpha := filepath.Join(vaultDir, "Projects", "Alpha.md")
+	require.NoError(t, os.WriteFile(alpha, []byte("# Alpha\n\nThe revised plan for alpha."), 0644))
+
+	mockClient.UpsertCalls = nil
+	result, err := indexer.IndexFiles(context.Background(), []string{alpha})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.UpdatedFiles)
+	assert.Contains(t, upsertedContent(mockClient, "Hub.md"), "revised plan")
+
+	// A full scan picks up the change as well
+	require.NoError(t, os.WriteFile(alpha, []byte("# Alpha\n\nThe final plan for alpha."), 0644))
+	mockClient.UpsertCalls = nil
+	result, err = indexer.ReindexVault(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.UpdatedFiles)
+	assert.Equal(t, 1, result.SkippedFiles)
+	assert.Contains(t, upsertedContent(mockClient, "Hub.md"), "final plan")
+}
+
+// upsertedContent returns the content of the upserted chunks of a note
+func upsertedContent(mockClient *MockChromaClient, filename string) string {
+	mockClient.mu.Lock()
+	defer mockClient.mu.Unlock()
+
+	var content []string
+	for _, call := range mockClient.UpsertCalls {
+		for _, doc := range call {
+			if doc.Metadata["filename"] == filename {
+				content = append(content, doc.Content)
+			}
+		}
+	}
+	return strings.Join(content, "\n")
+}
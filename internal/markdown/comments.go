@@ -0,0 +1,55 @@
+package markdown
+
+import "strings"
+
+// StripComments removes "%% comments %%", which may span lines, outside fenced code blocks.
+// Comments are private to the note and must never be embedded. Lines inside comments are kept
+// empty, so line numbers stay the same.
+func StripComments(content string) string {
+	lines := strings.Split(content, "\n")
+	inFence, inComment := "", false
+
+	for i, line := range lines {
+		if !inComment {
+			trimmed := strings.TrimSpace(line)
+			if inFence != "" {
+				if strings.HasPrefix(trimmed, inFence) {
+					inFence = ""
+				}
+				continue
+			}
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+				inFence = trimmed[:3]
+				continue
+			}
+			if !strings.Contains(line, "%%") {
+				continue
+			}
+		}
+
+		lines[i], inComment = stripLineComments(line, inComment)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// stripLineComments removes the comment parts of a line, given whether the line starts inside a
+// comment, and reports whether it ends inside one
+func stripLineComments(line string, inComment bool) (string, bool) {
+	var kept strings.Builder
+
+	for {
+		i := strings.Index(line, "%%")
+		if i < 0 {
+			if !inComment {
+				kept.WriteString(line)
+			}
+			return kept.String(), inComment
+		}
+		if !inComment {
+			kept.WriteString(line[:i])
+		}
+		inComment = !inComment
+		line = line[i+2:]
+	}
+}
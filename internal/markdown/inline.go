@@ -0,0 +1,276 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// blockIDRegex matches a "^block-id" marker ending a line
+	blockIDRegex = regexp.MustCompile(`^\^([A-Za-z0-9-]+)[ \t]*$`)
+
+	// lineFieldRegex matches a Dataview "key:: value" field filling a line; the key may be bold
+	lineFieldRegex = regexp.MustCompile(`^(?:\*\*|__)?([\p{L}\p{N}_][\p{L}\p{N}_\- ]*?)(?:\*\*|__)?::[ \t]*(.*)$`)
+
+	// bracketFieldRegex matches a Dataview "[key:: value]" or "(key:: value)" field; values may
+	// contain wikilinks
+	bracketFieldRegex = regexp.MustCompile(`^(?:\[([\p{L}\p{N}_][\p{L}\p{N}_\- ]*?)::[ \t]*((?:\[\[[^\]]*\]\]|[^\[\]])*?)[ \t]*\]|\(([\p{L}\p{N}_][\p{L}\p{N}_\- ]*?)::[ \t]*((?:\[\[[^\]]*\]\]|[^()\[\]])*?)[ \t]*\))`)
+)
+
+// parseLine parses the inline elements of a line, which may be a "key:: value" inline field
+func parseLine(s string) []*Node {
+	if match := lineFieldRegex.FindStringSubmatch(s); match != nil {
+		return []*Node{{Kind: Field, Literal: strings.TrimSpace(match[1]), Children: parseInlines(match[2])}}
+	}
+	return parseInlines(s)
+}
+
+// parseInlines parses the inline elements of a line
+func parseInlines(s string) []*Node {
+	var nodes []*Node
+	var text strings.Builder
+
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, &Node{Kind: Text, Literal: text.String()})
+			text.Reset()
+		}
+	}
+	add := func(node *Node) {
+		flush()
+		nodes = append(nodes, node)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			run := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			if node, next, ok := parseCodeSpan(s, i, run); ok {
+				add(node)
+				i = next
+			} else {
+				text.WriteString(s[i : i+run])
+				i += run
+			}
+			continue
+
+		case strings.HasPrefix(s[i:], "[[") || strings.HasPrefix(s[i:], "![["):
+			if node, next, ok := parseWikiLink(s, i); ok {
+				add(node)
+				i = next
+				continue
+			}
+
+		case (c == '[' || c == '(') && bracketFieldRegex.MatchString(s[i:]):
+			match := bracketFieldRegex.FindStringSubmatch(s[i:])
+			key, value := match[1], match[2]
+			if c == '(' {
+				key, value = match[3], match[4]
+			}
+			add(&Node{Kind: Field, Info: s[i : i+1], Literal: strings.TrimSpace(key), Children: parseInlines(value)})
+			i += len(match[0])
+			continue
+
+		case c == '[' || strings.HasPrefix(s[i:], "!["):
+			if node, next, ok := parseLink(s, i); ok {
+				add(node)
+				i = next
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~' || c == '=':
+			if node, next, ok := parseEmphasis(s, i); ok {
+				add(node)
+				i = next
+				continue
+			}
+
+		case (strings.HasPrefix(s[i:], "http://") || strings.HasPrefix(s[i:], "https://")) && (i == 0 || !isWordByte(s[i-1])):
+			end := i
+			for end < len(s) && !unicode.IsSpace(rune(s[end])) && s[end] != ')' {
+				end++
+			}
+			add(&Node{Kind: URL, Destination: s[i:end]})
+			i = end
+			continue
+
+		case strings.HasPrefix(s[i:], "<http://") || strings.HasPrefix(s[i:], "<https://"):
+			if end := strings.IndexByte(s[i:], '>'); end > 0 && !strings.ContainsAny(s[i:i+end], " \t") {
+				add(&Node{Kind: URL, Destination: s[i+1 : i+end]})
+				i += end + 1
+				continue
+			}
+
+		case c == '^' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			if match := blockIDRegex.FindStringSubmatch(s[i:]); match != nil {
+				add(&Node{Kind: BlockID, Literal: match[1]})
+				i = len(s)
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		text.WriteString(s[i : i+size])
+		i += size
+	}
+	flush()
+
+	return nodes
+}
+
+// parseCodeSpan parses a code span opened by a run of backticks at s[i]
+func parseCodeSpan(s string, i, run int) (*Node, int, bool) {
+	for j := i + run; j < len(s); {
+		k := strings.IndexByte(s[j:], '`')
+		if k < 0 {
+			break
+		}
+		start := j + k
+		end := start
+		for end < len(s) && s[end] == '`' {
+			end++
+		}
+		if end-start == run {
+			return &Node{Kind: Code, Literal: strings.TrimSpace(s[i+run : start])}, end, true
+		}
+		j = end
+	}
+	return nil, i, false
+}
+
+// parseWikiLink parses "[[target#fragment|alias]]" or an "![[embed]]" at s[i]
+func parseWikiLink(s string, i int) (*Node, int, bool) {
+	kind, start := WikiLink, i+2
+	if s[i] == '!' {
+		kind, start = Embed, i+3
+	}
+
+	end := strings.Index(s[start:], "]]")
+	if end < 0 {
+		return nil, i, false
+	}
+	inner := s[start : start+end]
+
+	node := &Node{Kind: kind}
+	if target, alias, ok := strings.Cut(inner, "|"); ok {
+		inner = strings.TrimSuffix(target, "\\") // Escaped pipe inside a table
+		node.Literal = strings.TrimSpace(alias)
+	}
+	target, fragment, _ := strings.Cut(inner, "#")
+	node.Destination = strings.TrimSpace(target)
+	node.Info = strings.TrimSpace(fragment)
+
+	return node, start + end + 2, true
+}
+
+// parseLink parses a "[text](destination)" link or a "![alt](source)" image at s[i]
+func parseLink(s string, i int) (*Node, int, bool) {
+	kind, start := Link, i+1
+	if s[i] == '!' {
+		kind, start = Image, i+2
+	}
+
+	closeText := matchingBracket(s, start-1, '[', ']')
+	if closeText < 0 || closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return nil, i, false
+	}
+	closeDestination := matchingBracket(s, closeText+1, '(', ')')
+	if closeDestination < 0 {
+		return nil, i, false
+	}
+
+	node := &Node{Kind: kind, Destination: strings.TrimSpace(s[closeText+2 : closeDestination])}
+	if kind == Image {
+		node.Children = []*Node{{Kind: Text, Literal: s[start:closeText]}}
+	} else {
+		node.Children = parseInlines(s[start:closeText])
+	}
+
+	return node, closeDestination + 1, true
+}
+
+// matchingBracket returns the index of the bracket closing the one at s[open], or -1
+func matchingBracket(s string, open int, opening, closing byte) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case opening:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseEmphasis parses emphasis ("*", "_", "**", "__"), strikethrough ("~~") or a highlight ("==")
+// opened at s[i]
+func parseEmphasis(s string, i int) (*Node, int, bool) {
+	var delimiters []string
+	if i+1 < len(s) && s[i+1] == s[i] {
+		delimiters = append(delimiters, s[i:i+2])
+	}
+	if s[i] == '*' || s[i] == '_' {
+		delimiters = append(delimiters, s[i:i+1])
+	}
+
+	for _, delimiter := range delimiters {
+		start := i + len(delimiter)
+		if start >= len(s) || unicode.IsSpace(rune(s[start])) {
+			continue
+		}
+		if delimiter[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+			continue // Underscores inside words, as in snake_case
+		}
+
+		if end := closingDelimiter(s, start, delimiter); end > start {
+			return &Node{Kind: Emphasis, Info: delimiter, Children: parseInlines(s[start:end])}, end + len(delimiter), true
+		}
+	}
+
+	return nil, i, false
+}
+
+// closingDelimiter returns the index of the delimiter closing emphasis whose content starts at
+// s[start], or -1
+func closingDelimiter(s string, start int, delimiter string) int {
+	for j := start + 1; j+len(delimiter) <= len(s); j++ {
+		if s[j:j+len(delimiter)] != delimiter || unicode.IsSpace(rune(s[j-1])) {
+			continue
+		}
+		after := j + len(delimiter)
+		if len(delimiter) == 1 && after < len(s) && s[after] == delimiter[0] {
+			j++ // Part of a double delimiter
+			continue
+		}
+		if delimiter[0] == '_' && after < len(s) && isWordByte(s[after]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// isASCIIPunct reports whether b is ASCII punctuation, which a backslash escapes
+func isASCIIPunct(b byte) bool {
+	return b < 0x80 && (unicode.IsPunct(rune(b)) || unicode.IsSymbol(rune(b)))
+}
+
+// isWordByte reports whether b is an ASCII letter or digit, or part of a multi-byte character
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b >= 0x80
+}
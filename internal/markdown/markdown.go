@@ -0,0 +1,143 @@
+// Package markdown parses Obsidian-flavoured markdown into a tree of blocks and inlines and renders
+// the plain text that is embedded. The indexer and the HTTP server both normalise text with it, so
+// notes and queries are embedded the same way.
+package markdown
+
+import (
+	"strings"
+
+	"github.com/mozillazg/go-unidecode"
+)
+
+// Kind is the type of a node
+type Kind int
+
+// Block kinds
+const (
+	Document Kind = iota
+	Frontmatter
+	Heading
+	Paragraph
+	List
+	ListItem
+	Blockquote
+	Callout
+	CalloutTitle
+	CodeBlock
+	Table
+	TableRow
+	ThematicBreak
+	Line // A source line of a heading, paragraph, list item, callout title or table row
+)
+
+// Inline kinds
+const (
+	Text Kind = iota + 100
+	Emphasis
+	Code
+	Link
+	Image
+	WikiLink
+	Embed
+	URL
+	BlockID
+	Field // Dataview inline field
+)
+
+// Node is a block or inline element of a markdown document
+type Node struct {
+	Kind        Kind
+	Line        int    // 1-based source line a block starts on; 0 for inlines
+	Level       int    // Heading level
+	Info        string // Code block language, callout type, task checkbox, link fragment, emphasis delimiter or inline field bracket
+	Literal     string // Text, code, frontmatter YAML, list item marker, wikilink alias, block ID or inline field key
+	Destination string // Link URL or wikilink target
+	Children    []*Node
+}
+
+// Version identifies how text is rendered for embedding. It is increased whenever the same
+// markdown renders differently, so notes indexed with an older version are embedded again.
+const Version = 1
+
+// QueryLanguages are the code block languages of Dataview and Tasks queries, whose sources are
+// never embedded
+var QueryLanguages = []string{"dataview", "dataviewjs", "tasks"}
+
+// Options control how a document is rendered for embedding
+type Options struct {
+	// KeepCodeBlocks keeps the content of fenced code blocks; query blocks are always left out
+	KeepCodeBlocks bool
+	// KeepMarkup keeps heading markers, list markers, emphasis delimiters and code span backticks.
+	// Highlight markers are always removed.
+	KeepMarkup bool
+	// DropHeadings are headings (case-insensitive) left out of the text, e.g. "Related Notes"
+	DropHeadings []string
+	// ASCII transliterates the text to ASCII, so the embedding model sees consistent tokens
+	ASCII bool
+}
+
+// EmbeddingOptions are the options notes and queries are normalised with
+var EmbeddingOptions = Options{
+	KeepCodeBlocks: true,
+	KeepMarkup:     true,
+	DropHeadings:   []string{"Related Notes", "Related Note", "References", "Reference"},
+	ASCII:          true,
+}
+
+// Word is a word of the rendered text with the source line it comes from
+type Word struct {
+	Text string
+	Line int
+}
+
+// Render renders the plain text of a document: one line per source line, with blank lines
+// between blocks
+func Render(doc *Node, opts Options) string {
+	var b strings.Builder
+	for i, seg := range renderSegments(doc, opts) {
+		if i > 0 {
+			if seg.block {
+				b.WriteString("\n\n")
+			} else {
+				b.WriteString("\n")
+			}
+		}
+		b.WriteString(seg.text)
+	}
+	return b.String()
+}
+
+// Words parses source and returns the words of its rendered text with their source lines
+func Words(source string, opts Options) []Word {
+	var words []Word
+	for _, seg := range renderSegments(Parse(source), opts) {
+		for _, word := range strings.Fields(seg.text) {
+			words = append(words, Word{Text: word, Line: seg.line})
+		}
+	}
+	return words
+}
+
+// Normalize parses source and renders it as a single line of text, as it is embedded
+func Normalize(source string, opts Options) string {
+	words := Words(source, opts)
+	texts := make([]string, len(words))
+	for i, word := range words {
+		texts[i] = word.Text
+	}
+	return strings.Join(texts, " ")
+}
+
+// CalloutLabel renders a callout type as a title-cased label, e.g. "faq" -> "Faq"
+func CalloutLabel(calloutType string) string {
+	if calloutType == "" {
+		return ""
+	}
+	label := strings.ReplaceAll(calloutType, "-", " ")
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+// transliterate converts text to ASCII equivalents
+func transliterate(text string) string {
+	return unidecode.Unidecode(text)
+}
@@ -0,0 +1,162 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestStripComments tests removal of inline and multi-line %% comments
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"inline", "Visible %%hidden%% text", "Visible  text"},
+		{"multi-line keeps line numbers", "Before\n%%\nsecret\nlines\n%%\nAfter", "Before\n\n\n\n\nAfter"},
+		{"comment ending mid-line", "Start %%secret\nmore%% end", "Start \n end"},
+		{"fenced code is kept", "```\n%% not a comment %%\n```", "```\n%% not a comment %%\n```"},
+		{"unterminated comment hides the rest", "Text\n%% draft\nnever shown", "Text\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, StripComments(tt.input))
+		})
+	}
+}
+
+// TestNormalize tests rendering plain text without markup or code
+func TestNormalize(t *testing.T) {
+	plain := Options{DropHeadings: EmbeddingOptions.DropHeadings, ASCII: true}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"headings", "# Title\n\nSetext\n---\nBody", "Title Setext Body"},
+		{"markdown link and URL", "See [the docs](https://example.com/a_(b)) or https://example.com.", "See the docs or"},
+		{"autolink", "Mail <https://example.com> now", "Mail now"},
+		{"wikilink alias", "Ask [[People/Ann|Ann]] first", "Ask Ann first"},
+		{"wikilink heading", "Read [[Note#Setup]] and [[#Local]]", "Read Note > Setup and Local"},
+		{"wikilink block", "Quote [[Note#^abc123]]", "Quote Note"},
+		{"embeds", "![[diagram.png]] ![[Other note]] ![alt text](pic.png)", "Other note alt text"},
+		{"emphasis", "**bold** *it* __b__ _i_ ~~gone~~ ==mark==", "bold it b i gone mark"},
+		{"snake case", "call my_var_name here", "call my_var_name here"},
+		{"inline code", "Run `go test` now", "Run go test now"},
+		{"escapes", `Not \*emphasis\*`, "Not *emphasis*"},
+		{"lists and tasks", "- one\n* two\n1. three\n- [ ] todo\n- [x] done", "one two three todo done"},
+		{"quote", "> quoted\n> text", "quoted text"},
+		{"callout", "> [!warning]- Check this\n> Body text", "Warning: Check this Body text"},
+		{"callout without title", "> [!tip]\n> Body", "Tip Body"},
+		{"table", "| Name | Role |\n| --- | :-: |\n| [[Ann\\|A]] | Lead \\| PM |", "Name Role A Lead | PM"},
+		{"code blocks dropped", "Before\n\n```go\nfunc main() {}\n```\n\nAfter", "Before After"},
+		{"unclosed code block", "Before\n```\ncode", "Before"},
+		{"frontmatter and rules", "---\ntitle: x\n---\nText\n\n***\n\nMore", "Text More"},
+		{"block ID", "Important line ^key-1", "Important line"},
+		{"comments", "Shown %%hidden%% text", "Shown text"},
+		{"related notes heading", "Body\n\n## Related Notes\n- [[A]]", "Body A"},
+		{"unicode", "Café — “quoted”", `Cafe -- "quoted"`},
+		{"broken link", "Broken [link without closing paren](", "Broken [link without closing paren]("},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Normalize(tt.input, plain))
+		})
+	}
+}
+
+// TestNormalizeEmbedding tests the text notes and queries are embedded as: structural markup and
+// code are kept
+func TestNormalizeEmbedding(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"headings", "# Title\n\nSetext\n---\nBody", "# Title ## Setext Body"},
+		{"emphasis", "**bold** *it* ~~gone~~ ==mark==", "**bold** *it* ~~gone~~ mark"},
+		{"link with emphasis", "Read [**Bold Link Text**](https://example.com/path)", "Read **Bold Link Text**"},
+		{"inline code", "Run `go test` now", "Run `go test` now"},
+		{"lists and tasks", "- one\n* two\n12. three\n- [ ] todo\n- [x] done", "- one * two 12. three - [ ] todo - [x] done"},
+		{"code blocks kept", "Before\n\n```go\nfunc main() {}\n```\n\nAfter", "Before func main() {} After"},
+		{"query blocks dropped", "Before\n\n```dataview\nLIST\n```\n\nAfter", "Before After"},
+		{"related notes heading", "Body\n\n## Related Notes\n- [[A]]", "Body - A"},
+		{"callout", "> [!warning]- Check **this**\n> Body text", "Warning: Check **this** Body text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Normalize(tt.input, EmbeddingOptions))
+		})
+	}
+}
+
+// TestNormalizeInlineFields tests that Dataview inline fields are rendered as in Obsidian's
+// reading view
+func TestNormalizeInlineFields(t *testing.T) {
+	input := "Status:: active\n**Owner**:: [[Ann]]\n- [ ] Call [due:: 2025-01-01] (who:: Bob)\n```\nkey:: kept\n```\nNot a field: here::"
+	expected := "Status: active Owner: Ann - [ ] Call due: 2025-01-01 Bob key:: kept Not a field: here::"
+
+	assert.Equal(t, expected, Normalize(input, EmbeddingOptions))
+}
+
+// TestRenderKeepCodeBlocks tests that code blocks can be kept, except queries
+func TestRenderKeepCodeBlocks(t *testing.T) {
+	source := "# Title\nIntro\n\n```go\nfmt.Println()\n```\n\n```dataview\nLIST\n```"
+
+	rendered := Render(Parse(source), Options{KeepCodeBlocks: true})
+
+	assert.Equal(t, "Title\n\nIntro\n\nfmt.Println()", rendered)
+}
+
+// TestParse tests the structure of a parsed document
+func TestParse(t *testing.T) {
+	source := "---\ntags: [a]\n---\n## Plan\n\n> [!note] Title\n> Body\n\n- [ ] Task\n  continued\n\n```tasks\nnot done\n```"
+
+	doc := Parse(source)
+	require.Len(t, doc.Children, 5)
+
+	assert.Equal(t, Frontmatter, doc.Children[0].Kind)
+	assert.Equal(t, "tags: [a]", doc.Children[0].Literal)
+
+	heading := doc.Children[1]
+	assert.Equal(t, Heading, heading.Kind)
+	assert.Equal(t, 2, heading.Level)
+	assert.Equal(t, 4, heading.Line)
+
+	callout := doc.Children[2]
+	assert.Equal(t, Callout, callout.Kind)
+	assert.Equal(t, "note", callout.Info)
+	require.Len(t, callout.Children, 2)
+	assert.Equal(t, CalloutTitle, callout.Children[0].Kind)
+	assert.Equal(t, Paragraph, callout.Children[1].Kind)
+	assert.Equal(t, 7, callout.Children[1].Line)
+
+	list := doc.Children[3]
+	assert.Equal(t, List, list.Kind)
+	require.Len(t, list.Children, 1)
+	assert.Equal(t, " ", list.Children[0].Info)
+	assert.Len(t, list.Children[0].Children, 2)
+
+	code := doc.Children[4]
+	assert.Equal(t, CodeBlock, code.Kind)
+	assert.Equal(t, "tasks", code.Info)
+	assert.Equal(t, "not done", code.Literal)
+}
+
+// TestWords tests that rendered words keep their source lines
+func TestWords(t *testing.T) {
+	source := "---\ntitle: x\n---\n# Guide\n\nFirst **line**\nsecond [[Note|line]]\n\n%%\nhidden\n%%\n- item"
+
+	assert.Equal(t, []Word{
+		{"#", 4}, {"Guide", 4},
+		{"First", 6}, {"**line**", 6},
+		{"second", 7}, {"line", 7},
+		{"-", 12}, {"item", 12},
+	}, Words(source, EmbeddingOptions))
+}
@@ -0,0 +1,291 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	atxHeadingRegex      = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextUnderlineRegex = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	fenceRegex           = regexp.MustCompile("^[ \t]*(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	quoteRegex           = regexp.MustCompile(`^ {0,3}> ?`)
+	calloutRegex         = regexp.MustCompile(`^\[!([\w-]+)\][+-]?[ \t]*(.*)$`)
+	listItemRegex        = regexp.MustCompile(`^[ \t]*([-*+]|\d{1,9}[.)])(?:[ \t]+(.*))?$`)
+	taskBoxRegex         = regexp.MustCompile(`^\[(.)\](?:[ \t]+|$)`)
+	tableDelimiterRegex  = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+)
+
+// Parse parses markdown into a document. Comments are removed first; line numbers refer to the
+// source as given.
+func Parse(source string) *Node {
+	lines := strings.Split(strings.ReplaceAll(StripComments(source), "\r\n", "\n"), "\n")
+	doc := &Node{Kind: Document, Line: 1}
+
+	start := 0
+	if end := frontmatterEnd(lines); end > 0 {
+		doc.Children = append(doc.Children, &Node{Kind: Frontmatter, Line: 1, Literal: strings.Join(lines[1:end-1], "\n")})
+		start = end
+	}
+	doc.Children = append(doc.Children, parseBlocks(lines[start:], start+1)...)
+
+	return doc
+}
+
+// frontmatterEnd returns the index of the first line after a leading YAML frontmatter block, or 0
+func frontmatterEnd(lines []string) int {
+	if len(lines) < 2 || strings.TrimSpace(lines[0]) != "---" {
+		return 0
+	}
+	for i := 1; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// parseBlocks parses lines into blocks; first is the source line number of lines[0]
+func parseBlocks(lines []string, first int) []*Node {
+	var blocks []*Node
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		number := first + i
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+
+		case fenceRegex.MatchString(line):
+			var block *Node
+			block, i = parseFence(lines, i, first)
+			blocks = append(blocks, block)
+
+		case atxHeadingRegex.MatchString(line):
+			match := atxHeadingRegex.FindStringSubmatch(line)
+			blocks = append(blocks, &Node{Kind: Heading, Line: number, Level: len(match[1]), Children: []*Node{lineNode(match[2], number)}})
+			i++
+
+		case isThematicBreak(line):
+			blocks = append(blocks, &Node{Kind: ThematicBreak, Line: number})
+			i++
+
+		case quoteRegex.MatchString(line):
+			end := i
+			var quoted []string
+			for end < len(lines) && quoteRegex.MatchString(lines[end]) {
+				quoted = append(quoted, quoteRegex.ReplaceAllString(lines[end], ""))
+				end++
+			}
+			blocks = append(blocks, parseQuote(quoted, number))
+			i = end
+
+		case listItemRegex.MatchString(line):
+			var block *Node
+			block, i = parseList(lines, i, first)
+			blocks = append(blocks, block)
+
+		case strings.Contains(line, "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "-") && tableDelimiterRegex.MatchString(lines[i+1]):
+			var block *Node
+			block, i = parseTable(lines, i, first)
+			blocks = append(blocks, block)
+
+		default:
+			var block *Node
+			block, i = parseParagraph(lines, i, first)
+			blocks = append(blocks, block)
+		}
+	}
+
+	return blocks
+}
+
+// startsBlock reports whether a line starts a block that interrupts a paragraph
+func startsBlock(line string) bool {
+	return fenceRegex.MatchString(line) || atxHeadingRegex.MatchString(line) || isThematicBreak(line) ||
+		quoteRegex.MatchString(line) || listItemRegex.MatchString(line)
+}
+
+// isThematicBreak reports whether a line is a horizontal rule such as "---", "***" or "_ _ _"
+func isThematicBreak(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 || trimmed == "" {
+		return false
+	}
+
+	marker, count := trimmed[0], 0
+	if marker != '-' && marker != '*' && marker != '_' {
+		return false
+	}
+	for i := 0; i < len(trimmed); i++ {
+		switch trimmed[i] {
+		case marker:
+			count++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+// lineNode parses the inlines of a source line
+func lineNode(text string, number int) *Node {
+	return &Node{Kind: Line, Line: number, Children: parseLine(text)}
+}
+
+// parseParagraph parses a paragraph starting at lines[i], which becomes a heading when it is
+// underlined with "===" or "---"
+func parseParagraph(lines []string, i, first int) (*Node, int) {
+	paragraph := &Node{Kind: Paragraph, Line: first + i}
+
+	end := i
+	for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+		if end > i {
+			if match := setextUnderlineRegex.FindStringSubmatch(lines[end]); match != nil {
+				paragraph.Kind = Heading
+				paragraph.Level = 1
+				if strings.HasPrefix(match[1], "-") {
+					paragraph.Level = 2
+				}
+				return paragraph, end + 1
+			}
+			if startsBlock(lines[end]) {
+				break
+			}
+		}
+		paragraph.Children = append(paragraph.Children, lineNode(strings.TrimSpace(lines[end]), first+end))
+		end++
+	}
+
+	return paragraph, end
+}
+
+// parseFence parses a fenced code block starting at lines[i]; an unclosed block runs to the end
+func parseFence(lines []string, i, first int) (*Node, int) {
+	match := fenceRegex.FindStringSubmatch(lines[i])
+	marker := match[1]
+	block := &Node{Kind: CodeBlock, Line: first + i, Info: strings.ToLower(match[2])}
+
+	end := i + 1
+	for end < len(lines) {
+		trimmed := strings.TrimSpace(lines[end])
+		if strings.HasPrefix(trimmed, marker) && strings.Trim(trimmed, marker[:1]) == "" {
+			break
+		}
+		end++
+	}
+	block.Literal = strings.Join(lines[i+1:min(end, len(lines))], "\n")
+
+	return block, min(end+1, len(lines))
+}
+
+// parseQuote parses the lines of a block quote, without their ">" markers, into a block quote or
+// a callout
+func parseQuote(quoted []string, number int) *Node {
+	match := calloutRegex.FindStringSubmatch(quoted[0])
+	if match == nil {
+		return &Node{Kind: Blockquote, Line: number, Children: parseBlocks(quoted, number)}
+	}
+
+	callout := &Node{Kind: Callout, Line: number, Info: strings.ToLower(match[1])}
+	callout.Children = append(callout.Children, &Node{Kind: CalloutTitle, Line: number, Children: []*Node{lineNode(match[2], number)}})
+	callout.Children = append(callout.Children, parseBlocks(quoted[1:], number+1)...)
+
+	return callout
+}
+
+// parseList parses consecutive list items starting at lines[i]. Nested items become items of the
+// same list; lines continuing an item are added to it.
+func parseList(lines []string, i, first int) (*Node, int) {
+	list := &Node{Kind: List, Line: first + i}
+	var item *Node
+
+	end := i
+	for end < len(lines) {
+		line := lines[end]
+
+		if strings.TrimSpace(line) == "" {
+			// The list goes on after blank lines with another item or indented content
+			next := end + 1
+			for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+				next++
+			}
+			if next == len(lines) || !(listItemRegex.MatchString(lines[next]) || strings.HasPrefix(lines[next], " ") || strings.HasPrefix(lines[next], "\t")) {
+				break
+			}
+			end = next
+			continue
+		}
+
+		trimmed := strings.TrimLeft(line, " \t")
+		if match := listItemRegex.FindStringSubmatch(line); match != nil && !isThematicBreak(line) {
+			text := match[2]
+			item = &Node{Kind: ListItem, Line: first + end, Literal: match[1]}
+			if box := taskBoxRegex.FindStringSubmatch(text); box != nil {
+				item.Info = box[1]
+				text = text[len(box[0]):]
+			}
+			item.Children = append(item.Children, lineNode(text, first+end))
+			list.Children = append(list.Children, item)
+		} else if startsBlock(trimmed) || item == nil {
+			break
+		} else {
+			item.Children = append(item.Children, lineNode(strings.TrimSpace(line), first+end))
+		}
+		end++
+	}
+
+	return list, end
+}
+
+// parseTable parses a table whose header row is lines[i]; the delimiter row is left out
+func parseTable(lines []string, i, first int) (*Node, int) {
+	table := &Node{Kind: Table, Line: first + i}
+	table.Children = append(table.Children, tableRow(lines[i], first+i))
+
+	end := i + 2
+	for end < len(lines) && strings.TrimSpace(lines[end]) != "" && strings.Contains(lines[end], "|") {
+		table.Children = append(table.Children, tableRow(lines[end], first+end))
+		end++
+	}
+
+	return table, end
+}
+
+// tableRow parses a table row into a line with the cells separated by spaces. Pipes that are
+// escaped or inside wikilinks and code do not separate cells.
+func tableRow(row string, number int) *Node {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, "\\|") {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	inLink, inCode := false, false
+	for i := 0; i < len(row); i++ {
+		switch {
+		case row[i] == '\\' && i+1 < len(row) && row[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+			continue
+		case row[i] == '`':
+			inCode = !inCode
+		case strings.HasPrefix(row[i:], "[["):
+			inLink = true
+		case strings.HasPrefix(row[i:], "]]"):
+			inLink = false
+		case row[i] == '|' && !inLink && !inCode:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(row[i])
+	}
+	cells = append(cells, strings.TrimSpace(cell.String()))
+
+	return &Node{Kind: TableRow, Line: number, Children: []*Node{lineNode(strings.Join(cells, " "), number)}}
+}
@@ -0,0 +1,215 @@
+package markdown
+
+import (
+	"path"
+	"strings"
+)
+
+// segment is a rendered source line
+type segment struct {
+	text  string
+	line  int
+	block bool // Starts a new block
+}
+
+// renderer collects the rendered lines of a document
+type renderer struct {
+	opts     Options
+	segments []segment
+	newBlock bool
+}
+
+// renderSegments renders a document into lines of plain text
+func renderSegments(doc *Node, opts Options) []segment {
+	r := &renderer{opts: opts}
+	r.block(doc)
+	return r.segments
+}
+
+// write adds a rendered line, skipping empty ones
+func (r *renderer) write(text string, line int) {
+	if r.opts.ASCII {
+		text = transliterate(text)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return
+	}
+	r.segments = append(r.segments, segment{text: text, line: line, block: r.newBlock})
+	r.newBlock = false
+}
+
+// block renders a block and its children
+func (r *renderer) block(node *Node) {
+	switch node.Kind {
+	case Frontmatter, ThematicBreak:
+		// Not part of the text
+
+	case Heading:
+		if r.droppedHeading(node) {
+			return
+		}
+		r.newBlock = true
+		r.lines(node.Children, r.marker(strings.Repeat("#", node.Level)))
+
+	case Paragraph:
+		r.newBlock = true
+		r.lines(node.Children, "")
+
+	case ListItem:
+		marker := node.Literal
+		if node.Info != "" {
+			marker += " [" + node.Info + "]"
+		}
+		r.lines(node.Children, r.marker(marker))
+
+	case Callout:
+		label := CalloutLabel(node.Info)
+		if title := r.inlineText(node.Children[0].Children[0].Children); title != "" {
+			label += ": " + title
+		}
+		r.newBlock = true
+		r.write(label, node.Line)
+		for _, child := range node.Children[1:] {
+			r.block(child)
+		}
+
+	case CodeBlock:
+		if !r.opts.KeepCodeBlocks || isQueryLanguage(node.Info) {
+			return
+		}
+		r.newBlock = true
+		for i, line := range strings.Split(node.Literal, "\n") {
+			r.write(line, node.Line+1+i)
+		}
+
+	case List, Table:
+		r.newBlock = true
+		for _, child := range node.Children {
+			if child.Kind == TableRow {
+				r.lines(child.Children, "")
+				continue
+			}
+			r.block(child)
+		}
+
+	default:
+		for _, child := range node.Children {
+			r.block(child)
+		}
+	}
+}
+
+// lines renders line nodes, starting the first with prefix
+func (r *renderer) lines(lines []*Node, prefix string) {
+	for i, line := range lines {
+		text := r.inlineText(line.Children)
+		if i == 0 && prefix != "" {
+			text = prefix + " " + text
+		}
+		r.write(text, line.Line)
+	}
+}
+
+// marker returns a heading or list marker when markup is kept, and "" otherwise
+func (r *renderer) marker(marker string) string {
+	if !r.opts.KeepMarkup {
+		return ""
+	}
+	return marker
+}
+
+// droppedHeading reports whether a heading is one of the headings left out of the text
+func (r *renderer) droppedHeading(node *Node) bool {
+	var text []string
+	for _, line := range node.Children {
+		text = append(text, plainText(line.Children))
+	}
+	heading := strings.TrimSpace(strings.Join(text, " "))
+
+	for _, dropped := range r.opts.DropHeadings {
+		if strings.EqualFold(heading, dropped) {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineText renders inlines with the renderer's options
+func (r *renderer) inlineText(nodes []*Node) string {
+	return renderInlines(nodes, r.opts.KeepMarkup)
+}
+
+// plainText renders inlines without markup
+func plainText(nodes []*Node) string {
+	return renderInlines(nodes, false)
+}
+
+// renderInlines renders inlines as text: links become their text, inline fields read
+// "key: value", and URLs, block IDs and embedded attachments are left out. Emphasis delimiters and backticks are kept with keepMarkup;
+// highlight markers never are.
+func renderInlines(nodes []*Node, keepMarkup bool) string {
+	var b strings.Builder
+	for _, node := range nodes {
+		switch node.Kind {
+		case Text:
+			b.WriteString(node.Literal)
+		case Code:
+			if keepMarkup {
+				b.WriteString("`" + node.Literal + "`")
+			} else {
+				b.WriteString(node.Literal)
+			}
+		case Emphasis:
+			if keepMarkup && node.Info != "==" {
+				b.WriteString(node.Info + renderInlines(node.Children, keepMarkup) + node.Info)
+			} else {
+				b.WriteString(renderInlines(node.Children, keepMarkup))
+			}
+		case Link, Image:
+			b.WriteString(renderInlines(node.Children, keepMarkup))
+		case Field:
+			// Rendered as in Obsidian's reading view: "(key:: value)" hides its key
+			if node.Info != "(" {
+				b.WriteString(node.Literal + ": ")
+			}
+			b.WriteString(renderInlines(node.Children, keepMarkup))
+		case Embed:
+			if ext := path.Ext(node.Destination); ext != "" && !strings.EqualFold(ext, ".md") {
+				continue
+			}
+			b.WriteString(wikiLinkText(node))
+		case WikiLink:
+			b.WriteString(wikiLinkText(node))
+		}
+	}
+	return b.String()
+}
+
+// wikiLinkText returns the text Obsidian shows for a wikilink: its alias, or the target followed
+// by the heading it links to, e.g. "Note > Heading"
+func wikiLinkText(node *Node) string {
+	if node.Literal != "" {
+		return node.Literal
+	}
+	if node.Info == "" || strings.HasPrefix(node.Info, "^") {
+		return node.Destination
+	}
+
+	headings := strings.Split(node.Info, "#")
+	heading := strings.TrimSpace(headings[len(headings)-1])
+	if node.Destination == "" {
+		return heading
+	}
+	return node.Destination + " > " + heading
+}
+
+// isQueryLanguage reports whether a code block language is a Dataview or Tasks query
+func isQueryLanguage(language string) bool {
+	for _, query := range QueryLanguages {
+		if language == query {
+			return true
+		}
+	}
+	return false
+}
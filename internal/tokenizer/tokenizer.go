@@ -0,0 +1,31 @@
+// Package tokenizer counts text in the tokens of an embedding model, so chunks can be sized to what
+// the model embeds instead of a number of characters.
+package tokenizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultMaxLength is the input length, in tokens including [CLS] and [SEP], that chroma-go's
+// default embedding function (all-MiniLM-L6-v2) truncates documents to
+const DefaultMaxLength = 256
+
+// Tokenizer counts the tokens an embedding model splits text into
+type Tokenizer interface {
+	// Count returns the number of tokens of text, without the special tokens the model adds
+	Count(text string) int
+	// MaxTokens returns how many tokens of text the model embeds; the rest of longer input is cut off
+	MaxTokens() int
+}
+
+// DefaultPath returns where chroma-go caches the tokenizer of its default embedding model. The file
+// is downloaded the first time the default embedding function is used.
+func DefaultPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".cache", "chroma", "onnx_models", "all-MiniLM-L6-v2", "onnx", "tokenizer.json"), nil
+}
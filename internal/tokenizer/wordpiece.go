@@ -0,0 +1,222 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/mozillazg/go-unidecode"
+)
+
+// WordPiece is the tokenizer of BERT models such as all-MiniLM-L6-v2: text is normalised, split at
+// whitespace and punctuation, and every word is split into the longest pieces found in the
+// vocabulary
+type WordPiece struct {
+	vocab         map[string]bool
+	lowercase     bool
+	unknown       string
+	prefix        string // Prefix of pieces continuing a word, "##"
+	maxWordLength int    // Longer words become a single unknown token
+	maxLength     int    // Model input length including the special tokens
+}
+
+// specialTokens is the number of tokens BERT models add to every input, [CLS] and [SEP]
+const specialTokens = 2
+
+// tokenizerFile is the part of a Hugging Face tokenizer.json read by Load
+type tokenizerFile struct {
+	Normalizer *struct {
+		Lowercase *bool `json:"lowercase"`
+	} `json:"normalizer"`
+	Model struct {
+		Type                    string         `json:"type"`
+		UnkToken                string         `json:"unk_token"`
+		ContinuingSubwordPrefix string         `json:"continuing_subword_prefix"`
+		MaxInputCharsPerWord    int            `json:"max_input_chars_per_word"`
+		Vocab                   map[string]int `json:"vocab"`
+	} `json:"model"`
+}
+
+// Load reads a WordPiece tokenizer from a Hugging Face tokenizer.json or a BERT vocab.txt.
+// maxLength is the model input length in tokens, including the special tokens.
+func Load(path string, maxLength int) (*WordPiece, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer %s: %w", path, err)
+	}
+
+	wp := &WordPiece{
+		vocab:         make(map[string]bool),
+		lowercase:     true,
+		unknown:       "[UNK]",
+		prefix:        "##",
+		maxWordLength: 100,
+		maxLength:     maxLength,
+	}
+
+	if !strings.EqualFold(filepath.Ext(path), ".json") {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if token := strings.TrimRight(scanner.Text(), "\r"); token != "" {
+				wp.vocab[token] = true
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read vocabulary %s: %w", path, err)
+		}
+		return wp, nil
+	}
+
+	var file tokenizerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizer %s: %w", path, err)
+	}
+	if file.Model.Type != "WordPiece" {
+		return nil, fmt.Errorf("unsupported tokenizer model %q in %s, expected WordPiece", file.Model.Type, path)
+	}
+
+	for token := range file.Model.Vocab {
+		wp.vocab[token] = true
+	}
+	if file.Model.UnkToken != "" {
+		wp.unknown = file.Model.UnkToken
+	}
+	if file.Model.ContinuingSubwordPrefix != "" {
+		wp.prefix = file.Model.ContinuingSubwordPrefix
+	}
+	if file.Model.MaxInputCharsPerWord > 0 {
+		wp.maxWordLength = file.Model.MaxInputCharsPerWord
+	}
+	if file.Normalizer != nil && file.Normalizer.Lowercase != nil {
+		wp.lowercase = *file.Normalizer.Lowercase
+	}
+
+	return wp, nil
+}
+
+// Count returns the number of tokens of text, without [CLS] and [SEP]
+func (wp *WordPiece) Count(text string) int {
+	count := 0
+	for _, word := range wp.words(text) {
+		count += len(wp.pieces(word))
+	}
+	return count
+}
+
+// Tokenize returns the tokens of text, without [CLS] and [SEP]
+func (wp *WordPiece) Tokenize(text string) []string {
+	var tokens []string
+	for _, word := range wp.words(text) {
+		tokens = append(tokens, wp.pieces(word)...)
+	}
+	return tokens
+}
+
+// MaxTokens returns how many tokens of text the model embeds
+func (wp *WordPiece) MaxTokens() int {
+	return max(wp.maxLength-specialTokens, 0)
+}
+
+// words normalises text like BERT's basic tokenizer and splits it into words: control characters
+// are removed, text is lowercased and stripped of accents, and CJK characters and punctuation
+// become words of their own
+func (wp *WordPiece) words(text string) []string {
+	var words []string
+	var word strings.Builder
+
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range text {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar:
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsControl(r) || unicode.Is(unicode.Cf, r):
+		case isPunctuation(r) || isCJK(r):
+			flush()
+			words = append(words, wp.normalize(r))
+		default:
+			word.WriteString(wp.normalize(r))
+		}
+	}
+	flush()
+
+	return words
+}
+
+// normalize lowercases a character and strips its accents. Accents are stripped by transliterating
+// accented Latin letters to their base letter, which matches BERT's NFD decomposition for them.
+func (wp *WordPiece) normalize(r rune) string {
+	if !wp.lowercase {
+		return string(r)
+	}
+	r = unicode.ToLower(r)
+	if r < unicode.MaxASCII {
+		return string(r)
+	}
+	if unicode.Is(unicode.Mn, r) {
+		return "" // Combining accent
+	}
+	if unicode.Is(unicode.Latin, r) {
+		if base := unidecode.Unidecode(string(r)); len(base) == 1 {
+			return strings.ToLower(base)
+		}
+	}
+	return string(r)
+}
+
+// pieces splits a word into the longest pieces in the vocabulary, or returns the unknown token
+func (wp *WordPiece) pieces(word string) []string {
+	runes := []rune(word)
+	if len(runes) > wp.maxWordLength {
+		return []string{wp.unknown}
+	}
+
+	var pieces []string
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		piece := ""
+		for ; end > start; end-- {
+			candidate := string(runes[start:end])
+			if start > 0 {
+				candidate = wp.prefix + candidate
+			}
+			if wp.vocab[candidate] {
+				piece = candidate
+				break
+			}
+		}
+		if piece == "" {
+			return []string{wp.unknown}
+		}
+		pieces = append(pieces, piece)
+		start = end
+	}
+
+	return pieces
+}
+
+// isPunctuation reports whether BERT splits words at r: ASCII symbols and Unicode punctuation
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// isCJK reports whether r is a CJK ideograph, which BERT treats as a word of its own
+func isCJK(r rune) bool {
+	return (r >= 0x4E00 && r <= 0x9FFF) || (r >= 0x3400 && r <= 0x4DBF) || (r >= 0x20000 && r <= 0x2A6DF) ||
+		(r >= 0x2A700 && r <= 0x2B73F) || (r >= 0x2B740 && r <= 0x2B81F) || (r >= 0x2B820 && r <= 0x2CEAF) ||
+		(r >= 0xF900 && r <= 0xFAFF) || (r >= 0x2F800 && r <= 0x2FA1F)
+}
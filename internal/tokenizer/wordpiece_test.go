@@ -0,0 +1,65 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testVocab is a small BERT vocabulary
+var testVocab = []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "the", "cafe", "un", "##aff", "##able", "note", "##s", "!", ",", ".", "-", "中"}
+
+// TestLoadVocabText tests WordPiece tokenization with a vocab.txt
+func TestLoadVocabText(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vocab.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(testVocab, "\n")+"\n"), 0644))
+
+	wp, err := Load(path, DefaultMaxLength)
+	require.NoError(t, err)
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"The unaffable notes!", []string{"the", "un", "##aff", "##able", "note", "##s", "!"}},
+		{"Café, the-notes.", []string{"cafe", ",", "the", "-", "note", "##s", "."}},
+		{"unknown words", []string{"[UNK]", "[UNK]"}},
+		{"中中", []string{"中", "中"}},
+		{"  \t\n", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, wp.Tokenize(tt.input), tt.input)
+		assert.Equal(t, len(tt.expected), wp.Count(tt.input), tt.input)
+	}
+
+	assert.Equal(t, DefaultMaxLength-2, wp.MaxTokens())
+	assert.Equal(t, []string{"[UNK]"}, wp.Tokenize(strings.Repeat("a", 101)), "overlong words are unknown")
+}
+
+// TestLoadTokenizerJSON tests reading a Hugging Face tokenizer.json
+func TestLoadTokenizerJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	config := `{
+		"normalizer": {"type": "BertNormalizer", "lowercase": false},
+		"model": {"type": "WordPiece", "unk_token": "<unk>", "continuing_subword_prefix": "@@",
+			"max_input_chars_per_word": 100, "vocab": {"<unk>": 0, "Note": 1, "@@s": 2}}
+	}`
+	require.NoError(t, os.WriteFile(path, []byte(config), 0644))
+
+	wp, err := Load(path, 128)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Note", "@@s", "<unk>"}, wp.Tokenize("Notes notes"))
+	assert.Equal(t, 126, wp.MaxTokens())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"model": {"type": "BPE", "vocab": {}}}`), 0644))
+	_, err = Load(path, 128)
+	assert.Error(t, err, "only WordPiece models are supported")
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"), 128)
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher recursively watches directories and reports debounced batches of changed markdown paths
+type Watcher struct {
+	fsWatcher *fsnotify.Watcher
+	debounce  time.Duration
+	dirs      map[string]bool // Directories currently being watched
+	skipDir   func(path string) bool
+	changes   chan []string
+}
+
+// New creates a watcher for the given root directories. Roots that do not exist are skipped, and
+// so are the subdirectories for which skipDir, if not nil, returns true.
+func New(roots []string, debounce time.Duration, skipDir func(path string) bool) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create filesystem watcher: %w", err)
+	}
+
+	w := &Watcher{
+		fsWatcher: fsWatcher,
+		debounce:  debounce,
+		dirs:      make(map[string]bool),
+		skipDir:   skipDir,
+		changes:   make(chan []string),
+	}
+
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			log.Printf("Directory %s does not exist, not watching it", root)
+			continue
+		}
+
+		if _, err := w.addRecursive(root); err != nil {
+			fsWatcher.Close()
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// Changes returns the channel on which batches of changed paths are delivered.
+// Paths may refer to created, modified or removed files as well as removed directories.
+func (w *Watcher) Changes() <-chan []string {
+	return w.changes
+}
+
+// Close stops watching all directories
+func (w *Watcher) Close() error {
+	return w.fsWatcher.Close()
+}
+
+// Run processes filesystem events until the context is cancelled, coalescing bursts of
+// events (e.g. an editor saving a note several times) into a single batch per debounce period
+func (w *Watcher) Run(ctx context.Context) {
+	defer close(w.changes)
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if w.handleEvent(event, pending) {
+				timer.Reset(w.debounce)
+			}
+
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Filesystem watcher error: %v", err)
+
+		case <-timer.C:
+			if len(pending) == 0 {
+				continue
+			}
+
+			batch := make([]string, 0, len(pending))
+			for path := range pending {
+				batch = append(batch, path)
+			}
+			sort.Strings(batch)
+			pending = make(map[string]bool)
+
+			select {
+			case w.changes <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// handleEvent records the paths affected by an event and reports whether anything was recorded
+func (w *Watcher) handleEvent(event fsnotify.Event, pending map[string]bool) bool {
+	path := filepath.Clean(event.Name)
+
+	switch {
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		// The path is gone; directories are reported so their tracked files can be purged
+		if w.dirs[path] {
+			w.removeRecursive(path)
+			pending[path] = true
+			return true
+		}
+		if isMarkdown(path) {
+			pending[path] = true
+			return true
+		}
+
+	case event.Has(fsnotify.Create):
+		info, err := os.Stat(path)
+		if err != nil {
+			return false // Already gone again
+		}
+		if info.IsDir() {
+			if w.skips(path) {
+				return false
+			}
+			// Watch new (or moved-in) directories and report the notes they already contain
+			files, err := w.addRecursive(path)
+			if err != nil {
+				log.Printf("Failed to watch new directory %s: %v", path, err)
+			}
+			for _, file := range files {
+				pending[file] = true
+			}
+			return len(files) > 0
+		}
+		if isMarkdown(path) {
+			pending[path] = true
+			return true
+		}
+
+	case event.Has(fsnotify.Write):
+		if isMarkdown(path) {
+			pending[path] = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// addRecursive watches root and all its subdirectories, returning the markdown files found
+func (w *Watcher) addRecursive(root string) ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != root && w.skips(path) {
+				return filepath.SkipDir
+			}
+			if err := w.fsWatcher.Add(path); err != nil {
+				return fmt.Errorf("failed to watch directory %s: %w", path, err)
+			}
+			w.dirs[path] = true
+		} else if isMarkdown(path) {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+
+	return files, err
+}
+
+// skips reports whether a directory is not to be watched
+func (w *Watcher) skips(path string) bool {
+	return w.skipDir != nil && w.skipDir(path)
+}
+
+// removeRecursive stops watching root and all its subdirectories
+func (w *Watcher) removeRecursive(root string) {
+	prefix := root + string(filepath.Separator)
+	for dir := range w.dirs {
+		if dir == root || strings.HasPrefix(dir, prefix) {
+			delete(w.dirs, dir)
+			w.fsWatcher.Remove(dir) // May already be removed by the kernel
+		}
+	}
+}
+
+// isMarkdown reports whether the path looks like a markdown note
+func isMarkdown(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".md")
+}
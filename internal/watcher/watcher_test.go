@@ -0,0 +1,179 @@
+package watcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// waitForBatch waits for the next batch of changes or fails the test after a timeout
+func waitForBatch(t *testing.T, w *Watcher) []string {
+	t.Helper()
+
+	select {
+	case batch := <-w.Changes():
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for watcher batch")
+		return nil
+	}
+}
+
+// TestWatcherCoalescesSaveBursts tests that repeated writes to a note are reported once
+func TestWatcherCoalescesSaveBursts(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := New([]string{tempDir}, 100*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	note := filepath.Join(tempDir, "note.md")
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(note, []byte("# Note\n\nSaved again."), 0644); err != nil {
+			t.Fatalf("Failed to write note: %v", err)
+		}
+	}
+	// Non-markdown files are ignored
+	if err := os.WriteFile(filepath.Join(tempDir, "workspace.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write non-markdown file: %v", err)
+	}
+
+	batch := waitForBatch(t, w)
+	if len(batch) != 1 || batch[0] != note {
+		t.Errorf("Expected a single batch entry for %s, got %v", note, batch)
+	}
+}
+
+// TestWatcherRecursiveDirectories tests that notes in new subdirectories and removals are reported
+func TestWatcherRecursiveDirectories(t *testing.T) {
+	tempDir := t.TempDir()
+
+	w, err := New([]string{tempDir}, 100*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	subDir := filepath.Join(tempDir, "Projects", "Alpha")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	// Give the watcher time to pick up the new directories before writing into them
+	time.Sleep(200 * time.Millisecond)
+
+	note := filepath.Join(subDir, "plan.md")
+	if err := os.WriteFile(note, []byte("# Plan"), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+
+	batch := waitForBatch(t, w)
+	if len(batch) != 1 || batch[0] != note {
+		t.Errorf("Expected batch with %s, got %v", note, batch)
+	}
+
+	if err := os.Remove(note); err != nil {
+		t.Fatalf("Failed to remove note: %v", err)
+	}
+
+	batch = waitForBatch(t, w)
+	if len(batch) != 1 || batch[0] != note {
+		t.Errorf("Expected removal batch with %s, got %v", note, batch)
+	}
+}
+
+// TestWatcherRemovedDirectoryStopsWatchingSubdirectories tests that removing or renaming a watched
+// directory also forgets its subdirectories, but not siblings sharing its name as a prefix
+func TestWatcherRemovedDirectoryStopsWatchingSubdirectories(t *testing.T) {
+	tempDir := t.TempDir()
+	projects := filepath.Join(tempDir, "Projects")
+	for _, dir := range []string{filepath.Join(projects, "Alpha", "Notes"), filepath.Join(tempDir, "Projects Archive")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+
+	w, err := New([]string{tempDir}, 100*time.Millisecond, nil)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	pending := make(map[string]bool)
+	if !w.handleEvent(fsnotify.Event{Name: projects, Op: fsnotify.Rename}, pending) {
+		t.Fatalf("Expected the renamed directory to be reported")
+	}
+	if !pending[projects] {
+		t.Errorf("Expected %s to be pending, got %v", projects, pending)
+	}
+
+	expected := []string{tempDir, filepath.Join(tempDir, "Projects Archive")}
+	var dirs []string
+	for dir := range w.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	if len(dirs) != len(expected) || dirs[0] != expected[0] || dirs[1] != expected[1] {
+		t.Errorf("Expected watched directories %v, got %v", expected, dirs)
+	}
+
+	watched := w.fsWatcher.WatchList()
+	sort.Strings(watched)
+	if len(watched) != len(expected) || watched[0] != expected[0] || watched[1] != expected[1] {
+		t.Errorf("Expected fsnotify watches %v, got %v", expected, watched)
+	}
+}
+
+// TestWatcherSkipsIgnoredDirectories tests that directories the indexer ignores are not watched,
+// neither at start nor when they appear later
+func TestWatcherSkipsIgnoredDirectories(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, dir := range []string{filepath.Join(tempDir, ".git", "objects"), filepath.Join(tempDir, "Notes")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+
+	skipDir := func(path string) bool {
+		base := filepath.Base(path)
+		return base == ".git" || base == ".trash"
+	}
+	w, err := New([]string{tempDir}, 100*time.Millisecond, skipDir)
+	if err != nil {
+		t.Fatalf("Failed to create watcher: %v", err)
+	}
+	defer w.Close()
+
+	trash := filepath.Join(tempDir, ".trash")
+	if err := os.MkdirAll(trash, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(trash, "deleted.md"), []byte("# Deleted"), 0644); err != nil {
+		t.Fatalf("Failed to write note: %v", err)
+	}
+	pending := make(map[string]bool)
+	if w.handleEvent(fsnotify.Event{Name: trash, Op: fsnotify.Create}, pending) {
+		t.Errorf("Expected the notes of an ignored directory not to be reported, got %v", pending)
+	}
+
+	expected := []string{tempDir, filepath.Join(tempDir, "Notes")}
+	watched := w.fsWatcher.WatchList()
+	sort.Strings(watched)
+	if len(watched) != len(expected) || watched[0] != expected[0] || watched[1] != expected[1] {
+		t.Errorf("Expected fsnotify watches %v, got %v", expected, watched)
+	}
+}